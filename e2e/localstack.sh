@@ -0,0 +1,26 @@
+#!/bin/bash
+
+# Runs the e2e tests against LocalStack, creating the secrets they read first
+
+set -euf -o pipefail
+
+export SNAGSBY_AWS_ENDPOINT_URL=${SNAGSBY_AWS_ENDPOINT_URL:-"http://localhost:4566"}
+export AWS_ACCESS_KEY_ID=${AWS_ACCESS_KEY_ID:-test}
+export AWS_SECRET_ACCESS_KEY=${AWS_SECRET_ACCESS_KEY:-test}
+export AWS_REGION=${AWS_REGION:-us-east-1}
+
+put_secret() {
+	aws --endpoint-url "$SNAGSBY_AWS_ENDPOINT_URL" secretsmanager create-secret \
+		--name "$1" --secret-string "$2" > /dev/null 2>&1 ||
+		aws --endpoint-url "$SNAGSBY_AWS_ENDPOINT_URL" secretsmanager put-secret-value \
+			--secret-id "$1" --secret-string "$2" > /dev/null
+}
+
+# Note the trailing single quote
+tricky='@^*309_!~``:*/\{}%()>$t'"'"
+acceptance=$(python -c 'import json, sys; print(json.dumps({"TRICKY_CHARACTERS": sys.argv[1], "STARTS_WITH_HASH": "#hello?world"}))' "$tricky")
+
+put_secret snagsby/acceptance "$acceptance"
+put_secret /snagsby/app/acceptance/recursive_tricky_characters "$tricky"
+
+./e2e/e2e.sh
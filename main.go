@@ -1,23 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/roverdotcom/snagsby/pkg"
 	"github.com/roverdotcom/snagsby/pkg/app"
 	"github.com/roverdotcom/snagsby/pkg/config"
 	"github.com/roverdotcom/snagsby/pkg/formatters"
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
 )
 
 var (
 	showVersion = false
 	setFail     = false
 	showSummary = false
+	timeout     time.Duration
+	merge       = string(app.MergeLastWins)
 )
 
 var format string
@@ -26,17 +31,49 @@ func main() {
 	flagSet := flag.NewFlagSet("snagsby", flag.ExitOnError)
 	flagSet.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Example usage: snagsby s3://my-bucket/my-config.json?region=us-west-2\n")
+		fmt.Fprintf(os.Stderr, "              snagsby exec [flags] [sources] -- command [args...]\n")
+		fmt.Fprintf(os.Stderr, "              snagsby explain [flags] [sources] [KEY...]\n")
 		flagSet.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Supported schemes: %s\n", strings.Join(resolvers.Schemes(), ", "))
 	}
 	flagSet.BoolVar(&showVersion, "v", false, "print version string")
 	flagSet.BoolVar(&setFail, "e", false, "fail on errors")
 	flagSet.BoolVar(&showSummary, "show-summary", false, "Show summary")
+	flagSet.StringVar(&merge, "merge", merge, "How to merge keys defined by more than one source: last-wins, first-wins or error-on-conflict")
+	flagSet.DurationVar(&timeout, "timeout", config.DefaultTimeout, "Timeout for resolving each source, 0 for no timeout")
 	flagSet.StringVar(&format, "o", "env", "Output")
 	flagSet.StringVar(&format, "output", "env", "Output")
-	flagSet.Parse(os.Args[1:])
+
+	// exec mode runs a command with the resolved environment instead of
+	// printing it
+	args := os.Args[1:]
+	var command []string
+	execMode := len(args) > 0 && args[0] == "exec"
+	if execMode {
+		var err error
+		args, command, err = app.SplitExecArgs(args[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			flagSet.Usage()
+			os.Exit(2)
+		}
+	}
+	// explain reports where each key came from instead of printing values
+	var explainKeys []string
+	explainMode := len(args) > 0 && args[0] == "explain"
+	if explainMode {
+		args = args[1:]
+	}
+	flagSet.Parse(args)
+
+	sources := flagSet.Args()
+	if explainMode {
+		sources, explainKeys = app.SplitExplainArgs(sources)
+	}
 
 	if showVersion {
 		fmt.Printf("snagsby version %s (aws sdk: %s golang: %s)\n", pkg.Version, aws.SDKVersion, runtime.Version())
+		fmt.Printf("schemes: %s\n", strings.Join(resolvers.Schemes(), ", "))
 		return
 	}
 
@@ -47,16 +84,29 @@ func main() {
 		os.Exit(2)
 	}
 
+	mergePolicy, err := app.ParseMergePolicy(merge)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	snagsbyConfig := config.NewConfig()
-	err := snagsbyConfig.SetSources(flagSet.Args(), os.Getenv("SNAGSBY_SOURCE"))
+	err = snagsbyConfig.SetSources(sources, os.Getenv("SNAGSBY_SOURCE"))
 	if err != nil {
 		fmt.Printf("Error parsing sources: %s\n", err)
 		os.Exit(1)
 	}
 
-	results := app.ResolveConfigSources(snagsbyConfig)
-	var resultsMap []map[string]string
+	snagsbyConfig.Timeout = timeout
+
+	results := app.ResolveConfigSources(context.Background(), snagsbyConfig)
+	app.InterpolateResults(results, mergePolicy)
+	var succeeded []*resolvers.Result
 	for _, result := range results {
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning processing snagsby source %s: %s\n", result.Source.URL.String(), warning)
+		}
+
 		if result.HasErrors() {
 			// Print errors to stderr
 			fmt.Fprintln(os.Stderr, "Error processing snagsby source:", result.Source.URL.String())
@@ -72,15 +122,52 @@ func main() {
 			continue
 		}
 
-		if showSummary {
-			fmt.Fprintf(os.Stderr, "%s (%d) => (%s)\n", result.Source.URL.String(), result.LenItems(), strings.Join(result.ItemKeys(), ", "))
-		}
-
-		resultsMap = append(resultsMap, result.Items)
+		succeeded = append(succeeded, result)
 	}
 
 	// Merge together our rendered sources which are listed in the order they
 	// were specified.
-	all := formatters.Merge(resultsMap)
+	merged, err := app.MergeResults(succeeded, mergePolicy)
+	for _, conflict := range merged.Conflicts {
+		fmt.Fprintln(os.Stderr, "Conflict:", conflict.Error())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error merging sources with %s\n", mergePolicy)
+		os.Exit(1)
+	}
+
+	// The summary lists the keys each source supplied, keys replaced by
+	// another source are marked as overridden
+	if showSummary {
+		for _, result := range succeeded {
+			supplied, overridden := merged.Summary(result)
+			line := fmt.Sprintf("%s (%d) => (%s)", result.Source.URL.String(), len(supplied), strings.Join(supplied, ", "))
+			if len(overridden) > 0 {
+				line += fmt.Sprintf(" overridden (%s)", strings.Join(overridden, ", "))
+			}
+			if objects := app.LoadedObjects(result); len(objects) > 0 {
+				line += fmt.Sprintf(" loaded (%s)", strings.Join(objects, ", "))
+			}
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+
+	if explainMode {
+		if err := app.Explain(os.Stdout, merged, explainKeys); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	all := merged.Items
+
+	if execMode {
+		// Exec only returns on failure
+		err := app.Exec(command, app.MergeEnviron(os.Environ(), all))
+		fmt.Fprintln(os.Stderr, "Error running command:", err)
+		os.Exit(1)
+	}
+
 	fmt.Print(formatter(all))
 }
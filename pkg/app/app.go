@@ -1,19 +1,30 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/roverdotcom/snagsby/pkg/clients"
 	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/connectors"
 	"github.com/roverdotcom/snagsby/pkg/resolvers"
 )
 
-// ResolveConfigSources resolves a source config out to results
-func ResolveConfigSources(snagsbyConfig *config.Config) []*resolvers.Result {
+// ResolveConfigSources resolves a source config out to results. Sources share
+// AWS configs, clients and fetched secrets for the duration of the call.
+func ResolveConfigSources(ctx context.Context, snagsbyConfig *config.Config) []*resolvers.Result {
+	ctx = clients.WithCache(ctx, clients.NewCache())
+	ctx = connectors.WithFetchCoordinator(ctx, connectors.NewFetchCoordinator())
+
 	var jobs []chan *resolvers.Result
 	var out []*resolvers.Result
 	for _, source := range snagsbyConfig.GetSources() {
 		job := make(chan *resolvers.Result, 1)
 		jobs = append(jobs, job)
 		go func(s *config.Source, c chan *resolvers.Result) {
-			job <- resolvers.ResolveSource(s)
+			c <- resolveSource(ctx, s, snagsbyConfig.Timeout)
 		}(source, job)
 	}
 
@@ -23,3 +34,51 @@ func ResolveConfigSources(snagsbyConfig *config.Config) []*resolvers.Result {
 
 	return out
 }
+
+// resolveSource resolves a single source, bounded by the source's ?timeout=
+// option or the default timeout when either is set. A resolver that doesn't
+// return once its context is done is abandoned with a timeout error.
+func resolveSource(ctx context.Context, source *config.Source, defaultTimeout time.Duration) *resolvers.Result {
+	timeout := defaultTimeout
+	if source != nil && source.URL != nil {
+		sourceTimeout, err := source.Timeout()
+		if err != nil {
+			return &resolvers.Result{Source: source, Errors: []error{err}}
+		}
+		if sourceTimeout > 0 {
+			timeout = sourceTimeout
+		}
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan *resolvers.Result, 1)
+	go func() {
+		done <- resolvers.ResolveSource(ctx, source)
+	}()
+
+	select {
+	case result := <-done:
+		// Lead with a clear explanation when the errors were caused by the context
+		if result.HasErrors() && ctx.Err() != nil {
+			result.Errors = append([]error{contextError(ctx, timeout)}, result.Errors...)
+		}
+		return result
+	case <-ctx.Done():
+		return &resolvers.Result{Source: source, Errors: []error{contextError(ctx, timeout)}}
+	}
+}
+
+func contextError(ctx context.Context, timeout time.Duration) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("cancelled: %w", ctx.Err())
+	}
+	if timeout > 0 {
+		return fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+	return fmt.Errorf("timed out: %w", ctx.Err())
+}
@@ -1,10 +1,14 @@
 package app
 
 import (
+	"context"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
 )
 
 func TestResolveConfigSources(t *testing.T) {
@@ -47,7 +51,7 @@ func TestResolveConfigSources(t *testing.T) {
 				cfg.Sources = append(cfg.Sources, &config.Source{URL: parsedURL})
 			}
 
-			results := ResolveConfigSources(cfg)
+			results := ResolveConfigSources(context.Background(), cfg)
 
 			if len(results) != tt.expectedCount {
 				t.Errorf("Expected %d results, got %d", tt.expectedCount, len(results))
@@ -67,3 +71,95 @@ func TestResolveConfigSources(t *testing.T) {
 		})
 	}
 }
+
+// blockingResolver waits for its context to finish, or forever when ignoreContext is set
+type blockingResolver struct {
+	ignoreContext bool
+}
+
+func (b *blockingResolver) Resolve(ctx context.Context, source *config.Source) *resolvers.Result {
+	result := &resolvers.Result{Source: source}
+	if b.ignoreContext {
+		select {}
+	}
+	<-ctx.Done()
+	result.AppendError(ctx.Err())
+	return result
+}
+
+func TestResolveConfigSourcesTimeout(t *testing.T) {
+	resolvers.Register("test-blocking", func(source *config.Source) (resolvers.Resolver, error) {
+		return &blockingResolver{}, nil
+	})
+	resolvers.Register("test-hung", func(source *config.Source) (resolvers.Resolver, error) {
+		return &blockingResolver{ignoreContext: true}, nil
+	})
+
+	tests := []struct {
+		name            string
+		sourceURL       string
+		timeout         time.Duration
+		expectedMessage string
+	}{
+		{
+			name:            "global timeout",
+			sourceURL:       "test-blocking://source",
+			timeout:         10 * time.Millisecond,
+			expectedMessage: "timed out after 10ms",
+		},
+		{
+			name:            "per source timeout overrides global timeout",
+			sourceURL:       "test-blocking://source?timeout=20ms",
+			timeout:         time.Hour,
+			expectedMessage: "timed out after 20ms",
+		},
+		{
+			name:            "resolver ignoring its context is abandoned",
+			sourceURL:       "test-hung://source?timeout=10ms",
+			expectedMessage: "timed out after 10ms",
+		},
+		{
+			name:            "invalid per source timeout",
+			sourceURL:       "test-blocking://source?timeout=never",
+			expectedMessage: "invalid timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsedURL, _ := url.Parse(tt.sourceURL)
+			cfg := config.NewConfig()
+			cfg.Timeout = tt.timeout
+			cfg.Sources = []*config.Source{{URL: parsedURL}}
+
+			results := ResolveConfigSources(context.Background(), cfg)
+			if len(results) != 1 || !results[0].HasErrors() {
+				t.Fatalf("Expected a single result with errors, got %v", results)
+			}
+			if msg := results[0].Errors[0].Error(); !strings.Contains(msg, tt.expectedMessage) {
+				t.Errorf("Expected error containing %q, got %q", tt.expectedMessage, msg)
+			}
+		})
+	}
+}
+
+func TestResolveConfigSourcesDefaultTimeout(t *testing.T) {
+	resolvers.Register("test-hung", func(source *config.Source) (resolvers.Resolver, error) {
+		return &blockingResolver{ignoreContext: true}, nil
+	})
+	defaultTimeout := config.DefaultTimeout
+	config.DefaultTimeout = 10 * time.Millisecond
+	defer func() { config.DefaultTimeout = defaultTimeout }()
+
+	parsedURL, _ := url.Parse("test-hung://source")
+	cfg := config.NewConfig()
+	cfg.Sources = []*config.Source{{URL: parsedURL}}
+
+	results := ResolveConfigSources(context.Background(), cfg)
+	if len(results) != 1 || !results[0].HasErrors() {
+		t.Fatalf("Expected a single result with errors, got %v", results)
+	}
+	if msg := results[0].Errors[0].Error(); !strings.Contains(msg, "timed out after 10ms") {
+		t.Errorf("Expected error containing %q, got %q", "timed out after 10ms", msg)
+	}
+}
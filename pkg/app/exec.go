@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// syscallExec replaces the current process, it's swapped out in tests
+var syscallExec = syscall.Exec
+
+// SplitExecArgs splits the arguments following the exec command on the first
+// "--" into snagsby arguments (flags and sources) and the command to run
+func SplitExecArgs(args []string) ([]string, []string, error) {
+	idx := slices.Index(args, "--")
+	if idx == -1 {
+		return nil, nil, fmt.Errorf("exec requires a command after --")
+	}
+	command := args[idx+1:]
+	if len(command) == 0 {
+		return nil, nil, fmt.Errorf("exec requires a command after --")
+	}
+	return args[:idx], command, nil
+}
+
+// MergeEnviron overlays items onto environ, a list of KEY=value strings as
+// returned by os.Environ. Items take precedence over existing variables.
+func MergeEnviron(environ []string, items map[string]string) []string {
+	out := make([]string, 0, len(environ)+len(items))
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := items[key]; ok {
+			continue
+		}
+		out = append(out, kv)
+	}
+
+	// Sort the keys for a predictable environment
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out = append(out, k+"="+items[k])
+	}
+
+	return out
+}
+
+// Exec replaces the current process with command, looked up on the PATH, running with env
+func Exec(command []string, env []string) error {
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return err
+	}
+	return syscallExec(path, command, env)
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitExecArgs(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		expectedArgs    []string
+		expectedCommand []string
+		expectError     bool
+	}{
+		{
+			name:            "flags and sources before command",
+			args:            []string{"-e", "file://base.snagsby", "--", "python", "app.py"},
+			expectedArgs:    []string{"-e", "file://base.snagsby"},
+			expectedCommand: []string{"python", "app.py"},
+		},
+		{
+			name:            "no sources",
+			args:            []string{"--", "env"},
+			expectedArgs:    []string{},
+			expectedCommand: []string{"env"},
+		},
+		{
+			name:            "command arguments are passed through untouched",
+			args:            []string{"sm://app", "--", "sh", "-c", "echo $FOO", "--", "-e"},
+			expectedArgs:    []string{"sm://app"},
+			expectedCommand: []string{"sh", "-c", "echo $FOO", "--", "-e"},
+		},
+		{
+			name:        "missing separator",
+			args:        []string{"sm://app", "env"},
+			expectError: true,
+		},
+		{
+			name:        "missing command",
+			args:        []string{"sm://app", "--"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, command, err := SplitExecArgs(tt.args)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("Expected args %v, got %v", tt.expectedArgs, args)
+			}
+			if !reflect.DeepEqual(command, tt.expectedCommand) {
+				t.Errorf("Expected command %v, got %v", tt.expectedCommand, command)
+			}
+		})
+	}
+}
+
+func TestMergeEnviron(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"FOO=from-env",
+		"WITH_EQUALS=a=b",
+	}
+	items := map[string]string{
+		"FOO": "from-snagsby",
+		"BAR": "new=value",
+	}
+
+	actual := MergeEnviron(environ, items)
+	expected := []string{
+		"PATH=/usr/bin",
+		"WITH_EQUALS=a=b",
+		"BAR=new=value",
+		"FOO=from-snagsby",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestExec(t *testing.T) {
+	var execPath string
+	var execArgs, execEnv []string
+	originalExec := syscallExec
+	defer func() { syscallExec = originalExec }()
+	syscallExec = func(argv0 string, argv []string, envv []string) error {
+		execPath, execArgs, execEnv = argv0, argv, envv
+		return nil
+	}
+
+	command := []string{"sh", "-c", "echo $FOO"}
+	env := []string{"FOO=bar"}
+	if err := Exec(command, env); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if execPath == "" || execPath == "sh" {
+		t.Errorf("Expected sh to be resolved to a full path, got %q", execPath)
+	}
+	if !reflect.DeepEqual(execArgs, command) {
+		t.Errorf("Expected args %v, got %v", command, execArgs)
+	}
+	if !reflect.DeepEqual(execEnv, env) {
+		t.Errorf("Expected env %v, got %v", env, execEnv)
+	}
+
+	if err := Exec([]string{"snagsby-command-does-not-exist"}, env); err == nil {
+		t.Error("Expected error for a missing command")
+	}
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SplitExplainArgs separates the arguments following the explain command into
+// sources, which are URLs, and the names of the keys to explain
+func SplitExplainArgs(args []string) ([]string, []string) {
+	var sources, keys []string
+	for _, arg := range args {
+		if strings.Contains(arg, "://") {
+			sources = append(sources, arg)
+		} else {
+			keys = append(keys, arg)
+		}
+	}
+	return sources, keys
+}
+
+// Explain writes where each merged key was read from to w, values are never
+// written. Every key is explained when keys is empty, otherwise an error is
+// returned for any key that no source set.
+func Explain(w io.Writer, merged *Merged, keys []string) error {
+	if len(keys) == 0 {
+		for key := range merged.Items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	var missing []string
+	for _, key := range keys {
+		origin, ok := merged.Origins[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s=<redacted>\n", key)
+		fmt.Fprintf(w, "  source: %s\n", origin.Source.URL.String())
+		if reference, ok := origin.References[key]; ok {
+			fmt.Fprintf(w, "  reference: %s\n", reference)
+		}
+		if overridden := merged.Overridden[key]; len(overridden) > 0 {
+			urls := make([]string, len(overridden))
+			for i, result := range overridden {
+				urls[i] = result.Source.URL.String()
+			}
+			fmt.Fprintf(w, "  overridden: %s\n", strings.Join(urls, ", "))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("not set by any source: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+	connectortesting "github.com/roverdotcom/snagsby/pkg/connectors/testing"
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
+)
+
+func TestSplitExplainArgs(t *testing.T) {
+	sources, keys := SplitExplainArgs([]string{"file://base.snagsby", "DB_HOST", "sm://prod/app", "API_KEY"})
+	if !reflect.DeepEqual(sources, []string{"file://base.snagsby", "sm://prod/app"}) {
+		t.Errorf("Unexpected sources %v", sources)
+	}
+	if !reflect.DeepEqual(keys, []string{"DB_HOST", "API_KEY"}) {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	base := makeResult("file://base.snagsby", map[string]string{"DB_HOST": "localhost", "PORT": "5432"})
+	prod := makeResult("file://prod.snagsby", map[string]string{"DB_HOST": "db.internal"})
+	prod.SetReference("DB_HOST", resolvers.Reference{Name: "sm://prod/rds#host"})
+	app := makeResult("sm://prod/app", map[string]string{"API_KEY": "secret-key"})
+	app.SetReference("API_KEY", resolvers.Reference{Name: "sm://prod/app", Version: "a1b2c3", Stage: "AWSCURRENT"})
+
+	merged, err := MergeResults([]*resolvers.Result{base, prod, app}, MergeLastWins)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Explain(&out, merged, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `API_KEY=<redacted>
+  source: sm://prod/app
+  reference: sm://prod/app (version a1b2c3, stage AWSCURRENT)
+DB_HOST=<redacted>
+  source: file://prod.snagsby
+  reference: sm://prod/rds#host
+  overridden: file://base.snagsby
+PORT=<redacted>
+  source: file://base.snagsby
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+	for _, value := range merged.Items {
+		if strings.Contains(out.String(), value) {
+			t.Errorf("Expected value %q to be redacted", value)
+		}
+	}
+
+	out.Reset()
+	err = Explain(&out, merged, []string{"PORT", "MISSING"})
+	if err == nil || !strings.Contains(err.Error(), "MISSING") {
+		t.Errorf("Expected an error for MISSING, got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "PORT=<redacted>") {
+		t.Errorf("Expected PORT to be explained, got %s", out.String())
+	}
+}
+
+func TestExplainManifest(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.yaml")
+	manifest := "items:\n  - name: prod/db-password\n    env: DB_PASSWORD\n"
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	connector := &connectortesting.MockSecretsConnector{
+		GetSecretsFunc: func(keys []string) (map[string]string, []error) {
+			return map[string]string{"prod/db-password": "hunter2"}, nil
+		},
+		SecretVersions: map[string]string{"prod/db-password": "d4e5f6"},
+	}
+	sourceURL, _ := url.Parse("manifest://" + manifestPath)
+	result := resolvers.NewManifestResolver(connector).Resolve(context.Background(), &config.Source{URL: sourceURL})
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	merged, err := MergeResults([]*resolvers.Result{result}, MergeLastWins)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var out bytes.Buffer
+	if err := Explain(&out, merged, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "DB_PASSWORD=<redacted>\n" +
+		"  source: manifest://" + manifestPath + "\n" +
+		"  reference: sm://prod/db-password (version d4e5f6, stage AWSCURRENT)\n"
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
+)
+
+// InterpolateResults expands the ${VAR} references of results, which are
+// listed in the order their sources were specified. A variable that isn't set
+// by its own source reads the value earlier results without errors would
+// have when merged with policy. Templates that can't be expanded are reported
+// as errors on their result.
+func InterpolateResults(results []*resolvers.Result, policy MergePolicy) {
+	for i, result := range results {
+		if len(result.Templates) == 0 {
+			continue
+		}
+		earlier := results[:i]
+		result.Interpolate(func(name string) (string, bool) {
+			var value string
+			found := false
+			for _, e := range earlier {
+				v, ok := e.Items[name]
+				if !ok || e.HasErrors() || (found && policy == MergeFirstWins) {
+					continue
+				}
+				value, found = v, true
+			}
+			return value, found
+		})
+	}
+}
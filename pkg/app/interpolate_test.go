@@ -0,0 +1,56 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
+)
+
+func TestInterpolateResults(t *testing.T) {
+	makeResults := func() []*resolvers.Result {
+		base := makeResult("file://base.snagsby", map[string]string{"HOST": "localhost", "REGION": "us-east-1"})
+		failed := makeResult("s3://my-bucket/config.json", map[string]string{"HOST": "failed.internal"})
+		failed.Errors = []error{errors.New("access denied")}
+		prod := makeResult("file://production.snagsby", map[string]string{"HOST": "db.internal"})
+		appResult := makeResult("file://app.snagsby", map[string]string{"URL": "postgres://${HOST}/${REGION}"})
+		appResult.SetTemplate("URL")
+		return []*resolvers.Result{base, failed, prod, appResult}
+	}
+
+	tests := []struct {
+		policy   MergePolicy
+		expected string
+	}{
+		{MergeLastWins, "postgres://db.internal/us-east-1"},
+		{MergeErrorOnConflict, "postgres://db.internal/us-east-1"},
+		{MergeFirstWins, "postgres://localhost/us-east-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			results := makeResults()
+			InterpolateResults(results, tt.policy)
+
+			appResult := results[3]
+			if appResult.HasErrors() {
+				t.Fatalf("Unexpected errors: %v", appResult.Errors)
+			}
+			if !reflect.DeepEqual(appResult.Items, map[string]string{"URL": tt.expected}) {
+				t.Errorf("Expected URL=%s, got %v", tt.expected, appResult.Items)
+			}
+		})
+	}
+}
+
+func TestInterpolateResultsLaterSources(t *testing.T) {
+	appResult := makeResult("file://app.snagsby", map[string]string{"URL": "postgres://${HOST}"})
+	appResult.SetTemplate("URL")
+	prod := makeResult("file://production.snagsby", map[string]string{"HOST": "db.internal"})
+
+	InterpolateResults([]*resolvers.Result{appResult, prod}, MergeLastWins)
+	if !appResult.HasErrors() || len(appResult.Items) != 0 {
+		t.Errorf("Expected later sources not to be used, got %v %v", appResult.Items, appResult.Errors)
+	}
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
+)
+
+// MergePolicy decides which value is kept when more than one source defines
+// the same key
+type MergePolicy string
+
+const (
+	// MergeLastWins keeps the value from the last source listed
+	MergeLastWins MergePolicy = "last-wins"
+	// MergeFirstWins keeps the value from the first source listed
+	MergeFirstWins MergePolicy = "first-wins"
+	// MergeErrorOnConflict fails when sources define a key with different values
+	MergeErrorOnConflict MergePolicy = "error-on-conflict"
+)
+
+// MergePolicies lists the supported merge policies
+var MergePolicies = []MergePolicy{MergeLastWins, MergeFirstWins, MergeErrorOnConflict}
+
+// ParseMergePolicy returns the MergePolicy named by s
+func ParseMergePolicy(s string) (MergePolicy, error) {
+	for _, policy := range MergePolicies {
+		if string(policy) == s {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("invalid merge policy %q, expected one of %v", s, MergePolicies)
+}
+
+// Conflict records a key that two sources set to different values
+type Conflict struct {
+	Key     string
+	Earlier *config.Source
+	Later   *config.Source
+	// Kept is the source whose value was used, it's nil when conflicts are
+	// errors
+	Kept *config.Source
+}
+
+func (c *Conflict) Error() string {
+	msg := fmt.Sprintf("conflicting values for %s from %s and %s", c.Key, c.Earlier.URL.String(), c.Later.URL.String())
+	if c.Kept != nil {
+		msg += ", using " + c.Kept.URL.String()
+	}
+	return msg
+}
+
+// Merged holds merged items along with the source each one came from
+type Merged struct {
+	Items map[string]string
+	// Origins maps each key to the result that supplied its value
+	Origins map[string]*resolvers.Result
+	// Overridden maps each key to the results whose value was discarded, in
+	// the order they were listed
+	Overridden map[string][]*resolvers.Result
+	Conflicts  []*Conflict
+}
+
+// Summary returns the sorted keys a result supplied to the merged items and
+// the sorted keys it defined that were overridden by another source
+func (m *Merged) Summary(result *resolvers.Result) ([]string, []string) {
+	var supplied, overridden []string
+	for key := range result.Items {
+		if m.Origins[key] == result {
+			supplied = append(supplied, key)
+		} else {
+			overridden = append(overridden, key)
+		}
+	}
+	sort.Strings(supplied)
+	sort.Strings(overridden)
+	return supplied, overridden
+}
+
+// LoadedObjects returns the sorted S3 objects a result was read from, along
+// with the version ID or ETag of the content that was loaded
+func LoadedObjects(result *resolvers.Result) []string {
+	seen := map[string]bool{}
+	var objects []string
+	for _, reference := range result.References {
+		if reference.ETag == "" || seen[reference.String()] {
+			continue
+		}
+		seen[reference.String()] = true
+		objects = append(objects, reference.String())
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+// MergeResults merges the items of results, which are listed in the order
+// their sources were specified, according to policy. With
+// MergeErrorOnConflict an error listing every conflict is returned along with
+// the last-wins merge.
+func MergeResults(results []*resolvers.Result, policy MergePolicy) (*Merged, error) {
+	merged := &Merged{
+		Items:      make(map[string]string),
+		Origins:    make(map[string]*resolvers.Result),
+		Overridden: make(map[string][]*resolvers.Result),
+	}
+
+	for _, result := range results {
+		keys := result.ItemKeys()
+		sort.Strings(keys)
+		for _, key := range keys {
+			origin, exists := merged.Origins[key]
+			if !exists {
+				merged.Items[key] = result.Items[key]
+				merged.Origins[key] = result
+				continue
+			}
+
+			winner, loser := result, origin
+			if policy == MergeFirstWins {
+				winner, loser = origin, result
+			}
+			if origin.Items[key] != result.Items[key] {
+				conflict := &Conflict{Key: key, Earlier: origin.Source, Later: result.Source}
+				if policy != MergeErrorOnConflict {
+					conflict.Kept = winner.Source
+				}
+				merged.Conflicts = append(merged.Conflicts, conflict)
+			}
+			merged.Items[key] = winner.Items[key]
+			merged.Origins[key] = winner
+			merged.Overridden[key] = append(merged.Overridden[key], loser)
+		}
+	}
+
+	if policy == MergeErrorOnConflict && len(merged.Conflicts) > 0 {
+		errs := make([]error, len(merged.Conflicts))
+		for i, conflict := range merged.Conflicts {
+			errs[i] = conflict
+		}
+		return merged, errors.Join(errs...)
+	}
+
+	return merged, nil
+}
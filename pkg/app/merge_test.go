@@ -0,0 +1,125 @@
+package app
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
+)
+
+func makeResult(rawURL string, items map[string]string) *resolvers.Result {
+	parsedURL, _ := url.Parse(rawURL)
+	return &resolvers.Result{Source: &config.Source{URL: parsedURL}, Items: items}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	for _, policy := range MergePolicies {
+		parsed, err := ParseMergePolicy(string(policy))
+		if err != nil || parsed != policy {
+			t.Errorf("Expected %s to parse, got %s %v", policy, parsed, err)
+		}
+	}
+	if _, err := ParseMergePolicy("random-wins"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	base := makeResult("file://base.snagsby", map[string]string{"ONE": "in one", "OVER": "from one", "SAME": "same"})
+	two := makeResult("s3://bucket/two.json", map[string]string{"TWO": "in two", "OVER": "from two", "SAME": "same"})
+	results := []*resolvers.Result{base, two}
+
+	tests := []struct {
+		policy           MergePolicy
+		expected         map[string]string
+		expectedOrigin   *resolvers.Result
+		expectedConflict string
+		expectError      bool
+	}{
+		{
+			policy:           MergeLastWins,
+			expected:         map[string]string{"ONE": "in one", "TWO": "in two", "OVER": "from two", "SAME": "same"},
+			expectedOrigin:   two,
+			expectedConflict: "conflicting values for OVER from file://base.snagsby and s3://bucket/two.json, using s3://bucket/two.json",
+		},
+		{
+			policy:           MergeFirstWins,
+			expected:         map[string]string{"ONE": "in one", "TWO": "in two", "OVER": "from one", "SAME": "same"},
+			expectedOrigin:   base,
+			expectedConflict: "conflicting values for OVER from file://base.snagsby and s3://bucket/two.json, using file://base.snagsby",
+		},
+		{
+			policy:           MergeErrorOnConflict,
+			expected:         map[string]string{"ONE": "in one", "TWO": "in two", "OVER": "from two", "SAME": "same"},
+			expectedOrigin:   two,
+			expectedConflict: "conflicting values for OVER from file://base.snagsby and s3://bucket/two.json",
+			expectError:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			merged, err := MergeResults(results, tt.policy)
+			if tt.expectError {
+				if err == nil || err.Error() != tt.expectedConflict {
+					t.Errorf("Expected error %q, got %v", tt.expectedConflict, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(merged.Items, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, merged.Items)
+			}
+			if merged.Origins["OVER"] != tt.expectedOrigin {
+				t.Errorf("Expected OVER to come from %s", tt.expectedOrigin.Source.URL)
+			}
+			// Identical values are overridden but not a conflict
+			if len(merged.Overridden["SAME"]) != 1 {
+				t.Errorf("Expected SAME to be overridden once, got %v", merged.Overridden["SAME"])
+			}
+			if len(merged.Conflicts) != 1 || merged.Conflicts[0].Error() != tt.expectedConflict {
+				t.Errorf("Expected conflict %q, got %v", tt.expectedConflict, merged.Conflicts)
+			}
+		})
+	}
+}
+
+func TestMergedSummary(t *testing.T) {
+	base := makeResult("file://base.snagsby", map[string]string{"HOST": "localhost", "PORT": "5432", "DEBUG": "1"})
+	prod := makeResult("file://prod.snagsby", map[string]string{"HOST": "db.internal", "DEBUG": "0"})
+
+	merged, err := MergeResults([]*resolvers.Result{base, prod}, MergeLastWins)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	supplied, overridden := merged.Summary(base)
+	if !reflect.DeepEqual(supplied, []string{"PORT"}) || !reflect.DeepEqual(overridden, []string{"DEBUG", "HOST"}) {
+		t.Errorf("Unexpected summary for base: supplied %v overridden %v", supplied, overridden)
+	}
+
+	supplied, overridden = merged.Summary(prod)
+	if !reflect.DeepEqual(supplied, []string{"DEBUG", "HOST"}) || len(overridden) != 0 {
+		t.Errorf("Unexpected summary for prod: supplied %v overridden %v", supplied, overridden)
+	}
+}
+
+func TestLoadedObjects(t *testing.T) {
+	result := makeResult("s3://my-bucket/config/*", map[string]string{"HOST": "x", "PORT": "1", "PASSWORD": "y", "USER": "z"})
+	result.References = map[string]resolvers.Reference{
+		"HOST":     {Name: "s3://my-bucket/config/db.json", ETag: "abc"},
+		"PORT":     {Name: "s3://my-bucket/config/db.json", ETag: "abc"},
+		"USER":     {Name: "s3://my-bucket/config/app.json", Version: "v2", ETag: "def"},
+		"PASSWORD": {Name: "sm://prod/db", Version: "AWSCURRENT"},
+	}
+
+	expected := []string{"s3://my-bucket/config/app.json (version v2)", "s3://my-bucket/config/db.json (etag abc)"}
+	if objects := LoadedObjects(result); !reflect.DeepEqual(objects, expected) {
+		t.Errorf("Expected %v, got %v", expected, objects)
+	}
+	if objects := LoadedObjects(makeResult("file://base.snagsby", nil)); len(objects) != 0 {
+		t.Errorf("Expected no objects, got %v", objects)
+	}
+}
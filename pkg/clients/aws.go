@@ -3,11 +3,14 @@ package clients
 import (
 	"context"
 	"net/url"
+	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	snagsbyConfig "github.com/roverdotcom/snagsby/pkg/config"
 )
 
@@ -20,21 +23,70 @@ func GetAwsConfig(optFns ...func(*awsConfig.LoadOptions) error) (aws.Config, err
 	return awsConfig.LoadDefaultConfig(context.TODO(), optFns...)
 }
 
-func NewSecretsManagerClient(sourceURL *url.URL) (*secretsmanager.Client, error) {
-
-	cfg, err := GetAwsConfig(awsConfig.WithRetryer(func() aws.Retryer {
-		return retry.AddWithMaxAttempts(retry.NewStandard(), 10)
-	}))
-
-	if err != nil {
-		return nil, err
+// EndpointURL returns the endpoint AWS requests for a source are sent to
+// instead of the AWS endpoint for the region. It's set with the ?endpoint=
+// option or the SNAGSBY_AWS_ENDPOINT_URL environment variable, typically to
+// use a local stand-in such as LocalStack.
+func EndpointURL(sourceURL *url.URL) string {
+	if endpoint := sourceURL.Query().Get("endpoint"); endpoint != "" {
+		return endpoint
 	}
+	return os.Getenv("SNAGSBY_AWS_ENDPOINT_URL")
+}
 
-	region := sourceURL.Query().Get("region")
-	if region != "" {
-		cfg.Region = region
+// stsEndpointURL returns the endpoint roles of a source are assumed with. The
+// ?endpoint= option only applies to the service of the source, STS requests
+// go to the ?sts-endpoint= option or the SNAGSBY_AWS_ENDPOINT_URL environment
+// variable.
+func stsEndpointURL(sourceURL *url.URL) string {
+	if endpoint := sourceURL.Query().Get("sts-endpoint"); endpoint != "" {
+		return endpoint
 	}
-	svc := secretsmanager.NewFromConfig(cfg)
+	return os.Getenv("SNAGSBY_AWS_ENDPOINT_URL")
+}
+
+// newRetryer raises the attempts made for Secrets Manager and Parameter Store
+// requests, which are throttled when many secrets are fetched at once
+func newRetryer() aws.Retryer {
+	return retry.AddWithMaxAttempts(retry.NewStandard(), 10)
+}
+
+// NewSecretsManagerClient returns the Secrets Manager client for a source,
+// shared with other sources of the run that use the same AWS configuration
+func NewSecretsManagerClient(ctx context.Context, sourceURL *url.URL) (*secretsmanager.Client, error) {
+	return client(ctx, "secretsmanager", sourceURL, func(cfg aws.Config) *secretsmanager.Client {
+		return secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+			o.Retryer = newRetryer()
+			if endpoint := EndpointURL(sourceURL); endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		})
+	})
+}
+
+// NewSSMClient returns the Parameter Store client for a source, shared with
+// other sources of the run that use the same AWS configuration
+func NewSSMClient(ctx context.Context, sourceURL *url.URL) (*ssm.Client, error) {
+	return client(ctx, "ssm", sourceURL, func(cfg aws.Config) *ssm.Client {
+		return ssm.NewFromConfig(cfg, func(o *ssm.Options) {
+			o.Retryer = newRetryer()
+			if endpoint := EndpointURL(sourceURL); endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		})
+	})
+}
 
-	return svc, nil
+// NewS3Client returns the S3 client for a source, shared with other sources
+// of the run that use the same AWS configuration. Path style addressing is
+// used with custom endpoints, which rarely support bucket subdomains.
+func NewS3Client(ctx context.Context, sourceURL *url.URL) (*s3.Client, error) {
+	return client(ctx, "s3", sourceURL, func(cfg aws.Config) *s3.Client {
+		return s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if endpoint := EndpointURL(sourceURL); endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+				o.UsePathStyle = true
+			}
+		})
+	})
 }
@@ -0,0 +1,180 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Cache shares AWS configs and clients between the sources of a run so shared
+// config and credentials are loaded once rather than once per source. Entries
+// are keyed by the region, profile and role a source asks for and are only
+// built when a source first needs them.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+}
+
+type cacheKey struct {
+	kind        string
+	region      string
+	profile     string
+	roleARN     string
+	externalID  string
+	sessionName string
+	endpoint    string
+	stsEndpoint string
+}
+
+type cacheEntry struct {
+	once  sync.Once
+	value any
+	err   error
+}
+
+type cacheContextKey struct{}
+
+// NewCache returns an empty Cache
+func NewCache() *Cache {
+	return &Cache{entries: make(map[cacheKey]*cacheEntry)}
+}
+
+// WithCache returns a copy of ctx whose AWS clients are shared through cache
+func WithCache(ctx context.Context, cache *Cache) context.Context {
+	return context.WithValue(ctx, cacheContextKey{}, cache)
+}
+
+// cacheFromContext returns the cache of the run, a context without one gets a
+// new cache so nothing is shared
+func cacheFromContext(ctx context.Context) *Cache {
+	if cache, ok := ctx.Value(cacheContextKey{}).(*Cache); ok {
+		return cache
+	}
+	return NewCache()
+}
+
+// get returns the value stored under key, calling build to create it the
+// first time. Concurrent callers wait for a single build.
+func (c *Cache) get(key cacheKey, build func() (any, error)) (any, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = build()
+	})
+	return entry.value, entry.err
+}
+
+// sourceKey returns the cache key of a kind of value for a source
+func sourceKey(kind string, sourceURL *url.URL) cacheKey {
+	query := sourceURL.Query()
+	return cacheKey{
+		kind:        kind,
+		region:      query.Get("region"),
+		profile:     query.Get("profile"),
+		roleARN:     query.Get("role-arn"),
+		externalID:  query.Get("external-id"),
+		sessionName: query.Get("session-name"),
+		endpoint:    EndpointURL(sourceURL),
+		stsEndpoint: stsEndpointURL(sourceURL),
+	}
+}
+
+// config returns the AWS config for a source. The shared config is loaded
+// once per profile, selected with the ?profile= option or AWS_PROFILE, and
+// the ?region= option is applied to a copy of it. With a ?role-arn= option
+// the config uses credentials for that role, which are shared by every source
+// assuming it.
+func (c *Cache) config(sourceURL *url.URL) (aws.Config, error) {
+	key := sourceKey("config", sourceURL)
+	value, err := c.get(cacheKey{kind: key.kind, profile: key.profile}, func() (any, error) {
+		if key.profile == "" {
+			return GetAwsConfig()
+		}
+		cfg, err := GetAwsConfig(awsConfig.WithSharedConfigProfile(key.profile))
+		if err != nil {
+			return nil, fmt.Errorf("loading AWS config for profile %q: %w", key.profile, err)
+		}
+		return cfg, nil
+	})
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	cfg := value.(aws.Config).Copy()
+	if key.region != "" {
+		cfg.Region = key.region
+	}
+
+	if key.roleARN != "" {
+		key.kind = "role"
+		key.region = ""
+		key.endpoint = ""
+		credentials, _ := c.get(key, func() (any, error) {
+			return assumeRoleCredentials(cfg, key), nil
+		})
+		cfg.Credentials = credentials.(aws.CredentialsProvider)
+	}
+
+	return cfg, nil
+}
+
+// assumeRoleCredentials returns a provider of credentials for the role of key,
+// the role is assumed with STS on first use and again before credentials expire
+func assumeRoleCredentials(cfg aws.Config, key cacheKey) aws.CredentialsProvider {
+	stsClient := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if key.stsEndpoint != "" {
+			o.BaseEndpoint = aws.String(key.stsEndpoint)
+		}
+	})
+	provider := stscreds.NewAssumeRoleProvider(stsClient, key.roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = "snagsby"
+		if key.sessionName != "" {
+			o.RoleSessionName = key.sessionName
+		}
+		if key.externalID != "" {
+			o.ExternalID = aws.String(key.externalID)
+		}
+	})
+	return aws.NewCredentialsCache(provider)
+}
+
+// client returns the client of a kind for a source, calling build with the
+// source's AWS config the first time it's needed
+func client[T any](ctx context.Context, kind string, sourceURL *url.URL, build func(aws.Config) T) (T, error) {
+	key := sourceKey(kind, sourceURL)
+	for _, endpoint := range []string{key.endpoint, key.stsEndpoint} {
+		if endpoint == "" {
+			continue
+		}
+		if parsed, err := url.Parse(endpoint); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			var zero T
+			return zero, fmt.Errorf("invalid endpoint %q, expected a URL such as http://localhost:4566", endpoint)
+		}
+	}
+
+	cache := cacheFromContext(ctx)
+	value, err := cache.get(key, func() (any, error) {
+		cfg, err := cache.config(sourceURL)
+		if err != nil {
+			return nil, err
+		}
+		return build(cfg), nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return value.(T), nil
+}
@@ -0,0 +1,258 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCacheGet(t *testing.T) {
+	cache := NewCache()
+	var builds int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.get(cacheKey{kind: "test"}, func() (any, error) {
+				atomic.AddInt32(&builds, 1)
+				return "built", nil
+			})
+			if err != nil || value != "built" {
+				t.Errorf("Expected built, got %v %v", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if builds != 1 {
+		t.Errorf("Expected a single build, got %d", builds)
+	}
+}
+
+func TestSourceKey(t *testing.T) {
+	sourceURL, _ := url.Parse("sm://app?region=us-west-2&profile=prod&role-arn=arn:aws:iam::123456789012:role/app&version-stage=AWSPREVIOUS")
+	expected := cacheKey{kind: "sm", region: "us-west-2", profile: "prod", roleARN: "arn:aws:iam::123456789012:role/app"}
+	if key := sourceKey("sm", sourceURL); key != expected {
+		t.Errorf("Expected %v, got %v", expected, key)
+	}
+}
+
+func TestClientsShareCache(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+	ctx := WithCache(context.Background(), NewCache())
+
+	west, _ := url.Parse("sm://one?region=us-west-2")
+	westAgain, _ := url.Parse("file://config.snagsby?region=us-west-2")
+	east, _ := url.Parse("sm://two")
+
+	first, err := NewSecretsManagerClient(ctx, west)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, _ := NewSecretsManagerClient(ctx, westAgain)
+	if first != second {
+		t.Error("Expected sources with the same region to share a client")
+	}
+	if region := first.Options().Region; region != "us-west-2" {
+		t.Errorf("Expected region us-west-2, got %s", region)
+	}
+
+	other, _ := NewSecretsManagerClient(ctx, east)
+	if other == first {
+		t.Error("Expected sources with different regions to use different clients")
+	}
+	if region := other.Options().Region; region != "us-east-1" {
+		t.Errorf("Expected the default region us-east-1, got %s", region)
+	}
+
+	// Without a cache in the context nothing is shared
+	uncached, _ := NewSecretsManagerClient(context.Background(), west)
+	if uncached == first {
+		t.Error("Expected a new client without a cache")
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("SNAGSBY_AWS_ENDPOINT_URL", "http://localhost:4566")
+	ctx := WithCache(context.Background(), NewCache())
+
+	fromEnv, _ := url.Parse("s3://bucket/config.json")
+	fromQuery, _ := url.Parse("s3://bucket/config.json?endpoint=http://moto:5000")
+	if endpoint := EndpointURL(fromEnv); endpoint != "http://localhost:4566" {
+		t.Errorf("Expected the endpoint from the environment, got %s", endpoint)
+	}
+	if endpoint := EndpointURL(fromQuery); endpoint != "http://moto:5000" {
+		t.Errorf("Expected the endpoint from the query, got %s", endpoint)
+	}
+
+	s3Client, err := NewS3Client(ctx, fromQuery)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if options := s3Client.Options(); aws.ToString(options.BaseEndpoint) != "http://moto:5000" || !options.UsePathStyle {
+		t.Errorf("Expected a path style client for http://moto:5000, got %v %v", aws.ToString(options.BaseEndpoint), options.UsePathStyle)
+	}
+
+	smClient, err := NewSecretsManagerClient(ctx, fromEnv)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if endpoint := aws.ToString(smClient.Options().BaseEndpoint); endpoint != "http://localhost:4566" {
+		t.Errorf("Expected endpoint http://localhost:4566, got %s", endpoint)
+	}
+
+	invalid, _ := url.Parse("sm://secret?endpoint=localhost")
+	if _, err := NewSecretsManagerClient(ctx, invalid); err == nil {
+		t.Error("Expected an error for an endpoint without a scheme")
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	var assumed []url.Values
+	var hosts []string
+	var mu sync.Mutex
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		assumed = append(assumed, r.PostForm)
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASSUMED</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/app/snagsby</Arn>
+      <AssumedRoleId>ARO:snagsby</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	stsServer := httptest.NewServer(handler)
+	defer stsServer.Close()
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "default")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "default")
+	t.Setenv("SNAGSBY_AWS_ENDPOINT_URL", server.URL)
+	ctx := WithCache(context.Background(), NewCache())
+
+	role := "arn:aws:iam::123456789012:role/app"
+	smURL, _ := url.Parse("sm://app?role-arn=" + role + "&external-id=shared-secret")
+	// The endpoint of a source's service isn't used to assume its role
+	s3URL, _ := url.Parse("s3://bucket/config.json?region=us-west-2&role-arn=" + role + "&external-id=shared-secret&endpoint=http://127.0.0.1:1")
+	defaultURL, _ := url.Parse("sm://app")
+
+	smClient, err := NewSecretsManagerClient(ctx, smURL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s3Client, err := NewS3Client(ctx, s3URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, credentials := range []aws.CredentialsProvider{smClient.Options().Credentials, s3Client.Options().Credentials} {
+		creds, err := credentials.Retrieve(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if creds.AccessKeyID != "ASSUMED" {
+			t.Errorf("Expected assumed role credentials, got %s", creds.AccessKeyID)
+		}
+	}
+	// Sources assuming the same role share its credentials
+	if len(assumed) != 1 {
+		t.Fatalf("Expected the role to be assumed once, got %d", len(assumed))
+	}
+	params := assumed[0]
+	if params.Get("RoleArn") != role || params.Get("ExternalId") != "shared-secret" || params.Get("RoleSessionName") != "snagsby" {
+		t.Errorf("Unexpected AssumeRole parameters: %v", params)
+	}
+
+	stsURL, _ := url.Parse("ssm:///app?role-arn=arn:aws:iam::123456789012:role/other&sts-endpoint=" + stsServer.URL)
+	ssmClient, err := NewSSMClient(ctx, stsURL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := ssmClient.Options().Credentials.Retrieve(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(hosts) != 2 || hosts[1] != strings.TrimPrefix(stsServer.URL, "http://") {
+		t.Errorf("Expected the role to be assumed with the sts-endpoint option, got %v", hosts)
+	}
+
+	defaultClient, _ := NewSecretsManagerClient(ctx, defaultURL)
+	creds, err := defaultClient.Options().Credentials.Retrieve(ctx)
+	if err != nil || creds.AccessKeyID != "default" {
+		t.Errorf("Expected default credentials without a role, got %s %v", creds.AccessKeyID, err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+	os.WriteFile(configFile, []byte("[default]\nregion = us-east-1\n\n[profile dev]\nregion = eu-west-1\n\n[profile shared-services]\nregion = us-west-2\n"), 0o600)
+	os.WriteFile(credentialsFile, []byte("[default]\naws_access_key_id = DEFAULT\naws_secret_access_key = secret\n\n[dev]\naws_access_key_id = DEV\naws_secret_access_key = secret\n\n[shared-services]\naws_access_key_id = SHARED\naws_secret_access_key = secret\n"), 0o600)
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	ctx := WithCache(context.Background(), NewCache())
+
+	tests := []struct {
+		sourceURL      string
+		expectedRegion string
+		expectedKey    string
+	}{
+		{"sm://app", "us-east-1", "DEFAULT"},
+		{"sm://app?profile=dev", "eu-west-1", "DEV"},
+		{"sm://app?profile=shared-services&region=us-east-2", "us-east-2", "SHARED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sourceURL, func(t *testing.T) {
+			sourceURL, _ := url.Parse(tt.sourceURL)
+			client, err := NewSecretsManagerClient(ctx, sourceURL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if region := client.Options().Region; region != tt.expectedRegion {
+				t.Errorf("Expected region %s, got %s", tt.expectedRegion, region)
+			}
+			creds, err := client.Options().Credentials.Retrieve(ctx)
+			if err != nil || creds.AccessKeyID != tt.expectedKey {
+				t.Errorf("Expected credentials %s, got %s %v", tt.expectedKey, creds.AccessKeyID, err)
+			}
+		})
+	}
+
+	missing, _ := url.Parse("sm://app?profile=missing")
+	if _, err := NewSecretsManagerClient(ctx, missing); err == nil || !strings.Contains(err.Error(), `profile "missing"`) {
+		t.Errorf("Expected an error for the missing profile, got %v", err)
+	}
+}
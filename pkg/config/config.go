@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var commaSplit = regexp.MustCompile(`[\s|,]+`)
@@ -11,20 +14,61 @@ var commaSplit = regexp.MustCompile(`[\s|,]+`)
 // Source represents a single snagsby source URI
 type Source struct {
 	URL *url.URL
+	// optional is set by a ? before the source, ?file://local.snagsby
+	optional bool
+}
+
+// Optional reports whether a missing file, object or secret gives an empty
+// result instead of an error, set with a ? before the source or the
+// ?optional= option
+func (s *Source) Optional() (bool, error) {
+	raw := s.URL.Query().Get("optional")
+	if raw == "" {
+		return s.optional, nil
+	}
+	optional, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid optional option %q, expected true or false", raw)
+	}
+	return optional, nil
+}
+
+// Timeout returns the duration from the source's ?timeout= option, zero when
+// it isn't set
+func (s *Source) Timeout() (time.Duration, error) {
+	raw := s.URL.Query().Get("timeout")
+	if raw == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", raw, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: must be positive", raw)
+	}
+	return timeout, nil
 }
 
 func splitEnvArg(envArg string) []string {
 	return commaSplit.Split(strings.TrimSpace(envArg), -1)
 }
 
+// DefaultTimeout bounds the resolution of each source unless another timeout
+// is configured, so a hung AWS call can't stall a container start forever
+var DefaultTimeout = 30 * time.Second
+
 // NewConfig returns a new configuration
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{Timeout: DefaultTimeout}
 }
 
 // Config is the main configuration object
 type Config struct {
 	Sources []*Source
+	// Timeout bounds the resolution of each source that doesn't set its own
+	// ?timeout= option, zero means no timeout
+	Timeout time.Duration
 }
 
 // SetSources will set the internal sources slice from a list of strings or
@@ -47,11 +91,12 @@ func (c *Config) SetSources(args []string, env string) error {
 	}
 
 	for _, rawSource := range rawSources {
+		rawSource, optional := strings.CutPrefix(rawSource, "?")
 		url, err := url.Parse(rawSource)
 		if err != nil {
 			return err
 		}
-		c.Sources = append(c.Sources, &Source{url})
+		c.Sources = append(c.Sources, &Source{URL: url, optional: optional})
 	}
 
 	return nil
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSplitEnvArg(t *testing.T) {
@@ -106,3 +109,72 @@ func TestGetSources(t *testing.T) {
 		t.Errorf("Expected empty sources for new config, got %d", len(emptySources))
 	}
 }
+
+func TestSourceTimeout(t *testing.T) {
+	tests := []struct {
+		rawURL      string
+		expected    time.Duration
+		expectError bool
+	}{
+		{rawURL: "s3://bucket/config.json", expected: 0},
+		{rawURL: "s3://bucket/config.json?timeout=5s", expected: 5 * time.Second},
+		{rawURL: "sm://secret?region=us-west-2&timeout=1m30s", expected: 90 * time.Second},
+		{rawURL: "sm://secret?timeout=soon", expectError: true},
+		{rawURL: "sm://secret?timeout=-1s", expectError: true},
+	}
+
+	for _, tt := range tests {
+		parsedURL, _ := url.Parse(tt.rawURL)
+		timeout, err := (&Source{URL: parsedURL}).Timeout()
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("Expected error for %s", tt.rawURL)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", tt.rawURL, err)
+		}
+		if timeout != tt.expected {
+			t.Errorf("Expected timeout %s for %s, got %s", tt.expected, tt.rawURL, timeout)
+		}
+	}
+}
+
+func TestSourceOptional(t *testing.T) {
+	tests := []struct {
+		rawSource   string
+		expected    bool
+		expectError bool
+	}{
+		{rawSource: "file://local.snagsby", expected: false},
+		{rawSource: "?file://local.snagsby", expected: true},
+		{rawSource: "s3://bucket/config.json?optional=true", expected: true},
+		{rawSource: "?sm://secret?optional=false", expected: false},
+		{rawSource: "sm://secret?optional=maybe", expectError: true},
+	}
+
+	for _, tt := range tests {
+		config := NewConfig()
+		if err := config.SetSources([]string{tt.rawSource}, ""); err != nil {
+			t.Fatalf("Unexpected error parsing %s: %v", tt.rawSource, err)
+		}
+		source := config.Sources[0]
+		if strings.HasPrefix(source.URL.String(), "?") {
+			t.Errorf("Expected the ? prefix to be removed from %s", source.URL)
+		}
+		optional, err := source.Optional()
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("Expected error for %s", tt.rawSource)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", tt.rawSource, err)
+		}
+		if optional != tt.expected {
+			t.Errorf("Expected optional %t for %s, got %t", tt.expected, tt.rawSource, optional)
+		}
+	}
+}
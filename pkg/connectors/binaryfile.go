@@ -0,0 +1,60 @@
+package connectors
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// binaryDir returns the directory binary secrets are written to, the
+// ?binary-dir= option or $TMPDIR/snagsby. The default directory is shared by
+// every user of the machine, so it's only used if it's a directory owned by
+// the current user that nobody else can access
+func binaryDir(dir string) (string, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return "", err
+		}
+		return dir, nil
+	}
+
+	dir = filepath.Join(os.TempDir(), "snagsby")
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || !privateToCurrentUser(info) {
+		return "", fmt.Errorf("%s must be a directory owned by the current user with mode 0700, remove it or set ?binary-dir=", dir)
+	}
+	return dir, nil
+}
+
+// writeBinaryFile writes data to a temporary file next to filePath and renames
+// it over filePath, so a symlink planted at filePath is replaced rather than
+// followed. Anything at filePath that isn't a regular file is refused
+func writeBinaryFile(filePath string, data []byte) error {
+	if info, err := os.Lstat(filePath); err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("%s exists and isn't a regular file", filePath)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".snagsby-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
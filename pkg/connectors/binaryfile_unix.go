@@ -0,0 +1,16 @@
+//go:build unix
+
+package connectors
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// privateToCurrentUser reports whether the file described by info is owned by
+// the user running snagsby and only accessible by them
+func privateToCurrentUser(info fs.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid() && info.Mode().Perm() == 0o700
+}
@@ -0,0 +1,96 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FetchCoordinator shares secret fetches between the sources of a run, each
+// secret is fetched once no matter how many sources reference it. Concurrent
+// requests for a secret wait for the fetch already in flight.
+type FetchCoordinator struct {
+	mu      sync.Mutex
+	fetches map[fetchKey]*sharedFetch
+}
+
+// fetchKey identifies a secret value, sources that fetch the same secret with
+// different options get their own fetch
+type fetchKey struct {
+	name      string
+	versionID string
+	stage     string
+	region    string
+	profile   string
+	roleARN   string
+	endpoint  string
+	binary    string
+	binaryDir string
+}
+
+type sharedFetch struct {
+	done    chan struct{}
+	value   string
+	version string
+	err     error
+	// interrupted is set when the context of the fetching source was done
+	interrupted bool
+}
+
+type fetchCoordinatorContextKey struct{}
+
+// NewFetchCoordinator returns an empty FetchCoordinator
+func NewFetchCoordinator() *FetchCoordinator {
+	return &FetchCoordinator{fetches: make(map[fetchKey]*sharedFetch)}
+}
+
+// WithFetchCoordinator returns a copy of ctx whose secret fetches are shared
+// through coordinator
+func WithFetchCoordinator(ctx context.Context, coordinator *FetchCoordinator) context.Context {
+	return context.WithValue(ctx, fetchCoordinatorContextKey{}, coordinator)
+}
+
+func fetchCoordinatorFromContext(ctx context.Context) *FetchCoordinator {
+	coordinator, _ := ctx.Value(fetchCoordinatorContextKey{}).(*FetchCoordinator)
+	return coordinator
+}
+
+// claim returns the fetch for key and whether the caller owns it. Owners
+// must fetch the secret and call complete.
+func (c *FetchCoordinator) claim(key fetchKey) (*sharedFetch, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fetch, ok := c.fetches[key]; ok {
+		return fetch, false
+	}
+	fetch := &sharedFetch{done: make(chan struct{})}
+	c.fetches[key] = fetch
+	return fetch, true
+}
+
+// complete publishes the result of a fetch along with the version ID of the
+// value. Fetches interrupted by the owner's context are forgotten so later
+// requests fetch the secret again.
+func (c *FetchCoordinator) complete(ctx context.Context, key fetchKey, fetch *sharedFetch, value, version string, err error) {
+	fetch.value, fetch.version, fetch.err = value, version, err
+	if ctx.Err() != nil {
+		fetch.interrupted = true
+		c.mu.Lock()
+		if c.fetches[key] == fetch {
+			delete(c.fetches, key)
+		}
+		c.mu.Unlock()
+	}
+	close(fetch.done)
+}
+
+// wait returns the result of the fetch once it completes and whether the
+// fetch was interrupted, or the error of ctx if it's done first
+func (f *sharedFetch) wait(ctx context.Context) (string, bool, error) {
+	select {
+	case <-f.done:
+		return f.value, f.interrupted, f.err
+	case <-ctx.Done():
+		return "", false, fmt.Errorf("waiting for secret fetched by another source: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,137 @@
+package connectors
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/roverdotcom/snagsby/pkg/config"
+)
+
+func newConnectorForURL(rawURL string, client SecretsManagerAPIClient) *SecretsManagerConnector {
+	sourceURL, _ := url.Parse(rawURL)
+	return NewSecretsManagerConnectorWithClient(client, &config.Source{URL: sourceURL})
+}
+
+// TestGetSecretsSharedFetches tests that connectors of different sources fetch
+// a secret once when they share a fetch coordinator
+func TestGetSecretsSharedFetches(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	mockClient := &mockSecretsManagerClient{
+		getSecretValueFunc: func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+			name := aws.ToString(params.SecretId) + "@" + aws.ToString(params.VersionStage)
+			mu.Lock()
+			calls[name]++
+			mu.Unlock()
+			// Keep fetches in flight long enough to overlap
+			time.Sleep(20 * time.Millisecond)
+			return &secretsmanager.GetSecretValueOutput{SecretString: aws.String("value-" + name), VersionId: aws.String("version-" + name)}, nil
+		},
+	}
+
+	ctx := WithFetchCoordinator(context.Background(), NewFetchCoordinator())
+	requests := []struct {
+		connector *SecretsManagerConnector
+		keys      []string
+	}{
+		{newConnectorForURL("file://base.snagsby", mockClient), []string{"shared/api-key", "base/only"}},
+		{newConnectorForURL("file://prod.snagsby", mockClient), []string{"shared/api-key", "prod/only"}},
+		{newConnectorForURL("manifest://manifest.yaml", mockClient), []string{"shared/api-key"}},
+		{newConnectorForURL("sm://shared/api-key?version-stage=AWSPREVIOUS", mockClient), []string{"shared/api-key"}},
+	}
+
+	var wg sync.WaitGroup
+	for _, request := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			secrets, errs := request.connector.GetSecrets(ctx, request.keys)
+			if len(errs) != 0 {
+				t.Errorf("Unexpected errors: %v", errs)
+			}
+			if len(secrets) != len(request.keys) {
+				t.Errorf("Expected %d secrets, got %v", len(request.keys), secrets)
+			}
+		}()
+	}
+	wg.Wait()
+
+	expected := map[string]int{
+		"shared/api-key@":            1,
+		"shared/api-key@AWSPREVIOUS": 1,
+		"base/only@":                 1,
+		"prod/only@":                 1,
+	}
+	for name, count := range expected {
+		if calls[name] != count {
+			t.Errorf("Expected %s to be fetched %d times, got %d", name, count, calls[name])
+		}
+	}
+
+	// Sources sharing a fetch report the version that was loaded
+	for i, expectedVersion := range []string{"version-shared/api-key@", "version-shared/api-key@", "version-shared/api-key@", "version-shared/api-key@AWSPREVIOUS"} {
+		if version := requests[i].connector.SecretVersion("shared/api-key"); version != expectedVersion {
+			t.Errorf("Expected source %d to report version %s, got %s", i, expectedVersion, version)
+		}
+	}
+
+	// Later requests in the run reuse the fetched value
+	value, err := requests[0].connector.GetSecret(ctx, "prod/only")
+	if err != nil || value != "value-prod/only@" {
+		t.Errorf("Expected the shared value, got %s %v", value, err)
+	}
+	if calls["prod/only@"] != 1 {
+		t.Errorf("Expected prod/only to be fetched once, got %d", calls["prod/only@"])
+	}
+}
+
+// TestGetSecretsSharedFetchInterrupted tests that a secret is fetched again
+// when the source that was fetching it is cancelled
+func TestGetSecretsSharedFetchInterrupted(t *testing.T) {
+	started := make(chan struct{})
+	var once sync.Once
+	mockClient := &mockSecretsManagerClient{
+		getSecretValueFunc: func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+			first := false
+			once.Do(func() { first = true })
+			if first {
+				close(started)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return &secretsmanager.GetSecretValueOutput{SecretString: aws.String("value")}, nil
+		},
+	}
+
+	ctx := WithFetchCoordinator(context.Background(), NewFetchCoordinator())
+	cancelledCtx, cancel := context.WithCancel(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := newConnectorForURL("file://slow.snagsby", mockClient).GetSecret(cancelledCtx, "shared")
+		done <- err
+	}()
+	<-started
+
+	result := make(chan string, 1)
+	go func() {
+		value, err := newConnectorForURL("file://other.snagsby", mockClient).GetSecret(ctx, "shared")
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		result <- value
+	}()
+
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("Expected the cancelled fetch to fail")
+	}
+	if value := <-result; value != "value" {
+		t.Errorf("Expected the secret to be fetched again, got %q", value)
+	}
+}
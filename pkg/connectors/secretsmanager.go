@@ -2,18 +2,34 @@ package connectors
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
-	"time"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
 	"github.com/roverdotcom/snagsby/pkg/clients"
 	"github.com/roverdotcom/snagsby/pkg/config"
 )
 
+// batchGetSecretValueSize is the number of secrets fetched per
+// BatchGetSecretValue call
+const batchGetSecretValueSize = 20
+
 type ListSecretsAPIClient interface {
 	ListSecrets(context.Context, *secretsmanager.ListSecretsInput, ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
 }
@@ -22,9 +38,14 @@ type GetSecretValueAPIClient interface {
 	GetSecretValue(context.Context, *secretsmanager.GetSecretValueInput, ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
 }
 
+type BatchGetSecretValueAPIClient interface {
+	BatchGetSecretValue(context.Context, *secretsmanager.BatchGetSecretValueInput, ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
+}
+
 type SecretsManagerAPIClient interface {
 	ListSecretsAPIClient
 	GetSecretValueAPIClient
+	BatchGetSecretValueAPIClient
 }
 
 // SecretsManagerConnector provides methods for retrieving secrets from AWS Secrets Manager.
@@ -33,14 +54,20 @@ type SecretsManagerAPIClient interface {
 type SecretsManagerConnector struct {
 	secretsmanagerClient SecretsManagerAPIClient
 	source               *config.Source
+	// batchDenied is set once BatchGetSecretValue is denied so later calls
+	// fetch each secret instead
+	batchDenied atomic.Bool
+	clientOnce  sync.Once
+	clientErr   error
+	// versions holds the version ID of each secret value fetched
+	versionsMu sync.Mutex
+	versions   map[string]string
 }
 
-func NewSecretsManagerConnector(source *config.Source) (*SecretsManagerConnector, error) {
-	secretsManagerClient, err := clients.NewSecretsManagerClient(source.URL)
-	if err != nil {
-		return nil, err
-	}
-	return &SecretsManagerConnector{secretsmanagerClient: secretsManagerClient, source: source}, nil
+// NewSecretsManagerConnector returns a connector for source, its AWS client is
+// only created once the connector is first used
+func NewSecretsManagerConnector(source *config.Source) *SecretsManagerConnector {
+	return &SecretsManagerConnector{source: source}
 }
 
 // NewSecretsManagerConnectorWithClient creates a new SecretsManagerConnector with a custom API client.
@@ -49,6 +76,40 @@ func NewSecretsManagerConnectorWithClient(client SecretsManagerAPIClient, source
 	return &SecretsManagerConnector{secretsmanagerClient: client, source: source}
 }
 
+// client returns the API client, creating it on first use
+func (sm *SecretsManagerConnector) client(ctx context.Context) (SecretsManagerAPIClient, error) {
+	sm.clientOnce.Do(func() {
+		if sm.secretsmanagerClient != nil {
+			return
+		}
+		client, err := clients.NewSecretsManagerClient(ctx, sm.source.URL)
+		if err != nil {
+			sm.clientErr = fmt.Errorf("creating secrets manager client: %w", err)
+			return
+		}
+		sm.secretsmanagerClient = client
+	})
+	return sm.secretsmanagerClient, sm.clientErr
+}
+
+// recordVersion stores the version ID of the value fetched for a secret
+func (sm *SecretsManagerConnector) recordVersion(secretName, versionID string) {
+	sm.versionsMu.Lock()
+	defer sm.versionsMu.Unlock()
+	if sm.versions == nil {
+		sm.versions = make(map[string]string)
+	}
+	sm.versions[secretName] = versionID
+}
+
+// SecretVersion returns the version ID of the value fetched for a secret, or
+// an empty string when the secret hasn't been fetched
+func (sm *SecretsManagerConnector) SecretVersion(secretName string) string {
+	sm.versionsMu.Lock()
+	defer sm.versionsMu.Unlock()
+	return sm.versions[secretName]
+}
+
 func (sm *SecretsManagerConnector) getConcurrencyOrDefault(keyLength int) int {
 	// Pull concurrency settings
 	getConcurrency, hasSetting := os.LookupEnv("SNAGSBY_SM_CONCURRENCY")
@@ -63,10 +124,7 @@ func (sm *SecretsManagerConnector) getConcurrencyOrDefault(keyLength int) int {
 }
 
 // fetchSecretValue retrieves a single secret value with version control
-func (sm *SecretsManagerConnector) fetchSecretValue(secretName string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func (sm *SecretsManagerConnector) fetchSecretValue(ctx context.Context, secretName string) (string, error) {
 	input := &secretsmanager.GetSecretValueInput{
 		SecretId: aws.String(secretName),
 	}
@@ -79,18 +137,64 @@ func (sm *SecretsManagerConnector) fetchSecretValue(secretName string) (string,
 		input.VersionId = aws.String(versionID)
 	}
 
-	getSecret, err := sm.secretsmanagerClient.GetSecretValue(ctx, input)
+	client, err := sm.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	getSecret, err := client.GetSecretValue(ctx, input)
 	if err != nil {
 		return "", fmt.Errorf("fetching secret %q: %w", secretName, err)
 	}
+	sm.recordVersion(secretName, aws.ToString(getSecret.VersionId))
 
 	if getSecret.SecretString == nil {
-		return "", fmt.Errorf("secret %s has no string value (may be binary data)", secretName)
+		return sm.binaryValue(secretName, getSecret.SecretBinary)
 	}
 
 	return *getSecret.SecretString, nil
 }
 
+// binaryFileRegexp matches characters that aren't kept in the names of files
+// binary secrets are written to
+var binaryFileRegexp = regexp.MustCompile(`[^\w.-]`)
+
+// binaryFileName returns the name of the file a binary secret is written to,
+// the secret name with unsafe characters replaced and a short hash of the full
+// name before its extension, so prod/keystore.jks and prod_keystore.jks don't
+// overwrite each other
+func binaryFileName(secretName string) string {
+	sum := sha256.Sum256([]byte(secretName))
+	ext := path.Ext(secretName)
+	base := binaryFileRegexp.ReplaceAllString(strings.TrimSuffix(secretName, ext), "_")
+	return base + "-" + hex.EncodeToString(sum[:4]) + binaryFileRegexp.ReplaceAllString(ext, "_")
+}
+
+// binaryValue converts a SecretBinary value according to the ?binary= option
+// of the source, base64 encodes the value and file writes it to a file in
+// ?binary-dir= whose path becomes the value
+func (sm *SecretsManagerConnector) binaryValue(secretName string, data []byte) (string, error) {
+	query := sm.source.URL.Query()
+	switch mode := query.Get("binary"); mode {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(data), nil
+	case "file":
+		dir, err := binaryDir(query.Get("binary-dir"))
+		if err != nil {
+			return "", fmt.Errorf("writing binary secret %s: %w", secretName, err)
+		}
+		filePath := filepath.Join(dir, binaryFileName(secretName))
+		if err := writeBinaryFile(filePath, data); err != nil {
+			return "", fmt.Errorf("writing binary secret %s: %w", secretName, err)
+		}
+		return filePath, nil
+	case "":
+		return "", fmt.Errorf("secret %s has a binary value, set ?binary=base64 or ?binary=file to use it", secretName)
+	default:
+		return "", fmt.Errorf("invalid binary option %q, expected base64 or file", mode)
+	}
+}
+
 type secretResult struct {
 	name  string
 	value string
@@ -98,9 +202,9 @@ type secretResult struct {
 }
 
 // worker processes secret fetch requests from the jobs channel
-func (sm *SecretsManagerConnector) worker(jobs <-chan string, results chan<- secretResult) {
+func (sm *SecretsManagerConnector) worker(ctx context.Context, jobs <-chan string, results chan<- secretResult) {
 	for secretName := range jobs {
-		value, err := sm.fetchSecretValue(secretName)
+		value, err := sm.fetchSecretValue(ctx, secretName)
 		results <- secretResult{
 			name:  secretName,
 			value: value,
@@ -109,12 +213,14 @@ func (sm *SecretsManagerConnector) worker(jobs <-chan string, results chan<- sec
 	}
 }
 
-// GetSecrets handles concurrent retrieval of secrets from secrets manager
-func (sm *SecretsManagerConnector) GetSecrets(keys []string) (map[string]string, []error) {
+// getSecretsEach concurrently fetches secrets one GetSecretValue call at a time
+func (sm *SecretsManagerConnector) getSecretsEach(ctx context.Context, keys []string) (map[string]string, map[string]error) {
 	keysLength := len(keys)
+	secrets := make(map[string]string)
+	errs := make(map[string]error)
 
 	if keysLength == 0 {
-		return map[string]string{}, nil
+		return secrets, errs
 	}
 
 	numWorkers := sm.getConcurrencyOrDefault(keysLength)
@@ -125,7 +231,7 @@ func (sm *SecretsManagerConnector) GetSecrets(keys []string) (map[string]string,
 
 	// Start worker goroutines
 	for w := 0; w < numWorkers; w++ {
-		go sm.worker(jobs, results)
+		go sm.worker(ctx, jobs, results)
 	}
 
 	// Send jobs
@@ -135,21 +241,256 @@ func (sm *SecretsManagerConnector) GetSecrets(keys []string) (map[string]string,
 	close(jobs)
 
 	// Collect results
-	secrets := make(map[string]string)
-	var errors []error
 	for i := 0; i < keysLength; i++ {
 		result := <-results
 		if result.err != nil {
-			errors = append(errors, result.err)
+			errs[result.name] = result.err
 		} else {
 			secrets[result.name] = result.value
 		}
 	}
 
-	return secrets, errors
+	return secrets, errs
+}
+
+// requestsVersion indicates whether the source selects a secret version, which
+// BatchGetSecretValue doesn't support
+func (sm *SecretsManagerConnector) requestsVersion() bool {
+	query := sm.source.URL.Query()
+	return query.Get("version-stage") != "" || query.Get("version-id") != ""
+}
+
+// isAccessDenied indicates whether err is an access denied API error
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException"
+}
+
+// batchGetSecrets fetches up to batchGetSecretValueSize secrets with a single
+// BatchGetSecretValue call. Errors for individual secrets are returned in the
+// map, a failure of the call itself is returned separately.
+func (sm *SecretsManagerConnector) batchGetSecrets(ctx context.Context, names []string) (map[string]string, map[string]error, error) {
+	requested := make(map[string]bool, len(names))
+	for _, name := range names {
+		requested[name] = true
+	}
+
+	client, err := sm.client(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := make(map[string]string)
+	errs := make(map[string]error)
+	input := &secretsmanager.BatchGetSecretValueInput{SecretIdList: names}
+	for {
+		output, err := client.BatchGetSecretValue(ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, entry := range output.SecretValues {
+			// Secrets may be requested by name or ARN
+			name := aws.ToString(entry.Name)
+			if !requested[name] {
+				name = aws.ToString(entry.ARN)
+			}
+			sm.recordVersion(name, aws.ToString(entry.VersionId))
+			if entry.SecretString != nil {
+				secrets[name] = *entry.SecretString
+				continue
+			}
+			value, err := sm.binaryValue(name, entry.SecretBinary)
+			if err != nil {
+				errs[name] = err
+				continue
+			}
+			secrets[name] = value
+		}
+		for _, apiErr := range output.Errors {
+			name := aws.ToString(apiErr.SecretId)
+			// Keep the error code so callers can tell missing secrets apart
+			errs[name] = fmt.Errorf("fetching secret %q: %w", name, &smithy.GenericAPIError{
+				Code:    aws.ToString(apiErr.ErrorCode),
+				Message: aws.ToString(apiErr.Message),
+			})
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	for _, name := range names {
+		_, found := secrets[name]
+		_, failed := errs[name]
+		if !found && !failed {
+			errs[name] = fmt.Errorf("fetching secret %q: missing from batch response", name)
+		}
+	}
+
+	return secrets, errs, nil
+}
+
+type batchResult struct {
+	names   []string
+	secrets map[string]string
+	errs    map[string]error
+	err     error
 }
 
-func (s *SecretsManagerConnector) ListSecrets(prefix string) ([]string, error) {
+// fetchSecrets fetches secrets in batches with BatchGetSecretValue, falling
+// back to a GetSecretValue call per secret when the batch API is denied or
+// the source selects a secret version
+func (sm *SecretsManagerConnector) fetchSecrets(ctx context.Context, keys []string) (map[string]string, map[string]error) {
+	if sm.requestsVersion() || sm.batchDenied.Load() {
+		return sm.getSecretsEach(ctx, keys)
+	}
+
+	secrets := make(map[string]string)
+	errs := make(map[string]error)
+	if len(keys) == 0 {
+		return secrets, errs
+	}
+
+	var batches [][]string
+	for start := 0; start < len(keys); start += batchGetSecretValueSize {
+		batches = append(batches, keys[start:min(start+batchGetSecretValueSize, len(keys))])
+	}
+
+	numWorkers := min(sm.getConcurrencyOrDefault(len(batches)), len(batches), 100)
+	jobs := make(chan []string, len(batches))
+	results := make(chan batchResult, len(batches))
+	for w := 0; w < numWorkers; w++ {
+		go func() {
+			for names := range jobs {
+				secrets, errs, err := sm.batchGetSecrets(ctx, names)
+				results <- batchResult{names: names, secrets: secrets, errs: errs, err: err}
+			}
+		}()
+	}
+	for _, batch := range batches {
+		jobs <- batch
+	}
+	close(jobs)
+
+	var denied []string
+	for range batches {
+		result := <-results
+		switch {
+		case result.err == nil:
+			maps.Copy(secrets, result.secrets)
+			maps.Copy(errs, result.errs)
+		case isAccessDenied(result.err):
+			denied = append(denied, result.names...)
+		default:
+			for _, name := range result.names {
+				errs[name] = fmt.Errorf("fetching secret %q: %w", name, result.err)
+			}
+		}
+	}
+
+	// Secrets in batches that were denied are fetched individually, access
+	// may be granted to GetSecretValue but not BatchGetSecretValue
+	if len(denied) > 0 {
+		sm.batchDenied.Store(true)
+		eachSecrets, eachErrs := sm.getSecretsEach(ctx, denied)
+		maps.Copy(secrets, eachSecrets)
+		maps.Copy(errs, eachErrs)
+	}
+
+	return secrets, errs
+}
+
+// fetchKey identifies a secret value fetched for this connector's source
+func (sm *SecretsManagerConnector) fetchKey(name string) fetchKey {
+	query := sm.source.URL.Query()
+	return fetchKey{
+		name:      name,
+		versionID: query.Get("version-id"),
+		stage:     query.Get("version-stage"),
+		region:    query.Get("region"),
+		profile:   query.Get("profile"),
+		roleARN:   query.Get("role-arn"),
+		endpoint:  clients.EndpointURL(sm.source.URL),
+		binary:    query.Get("binary"),
+		binaryDir: query.Get("binary-dir"),
+	}
+}
+
+// fetchShared fetches secrets through the fetch coordinator of the run, if
+// any, so a secret requested by several sources is only fetched once
+func (sm *SecretsManagerConnector) fetchShared(ctx context.Context, names []string) (map[string]string, map[string]error) {
+	coordinator := fetchCoordinatorFromContext(ctx)
+	if coordinator == nil {
+		return sm.fetchSecrets(ctx, names)
+	}
+
+	secrets := make(map[string]string)
+	errs := make(map[string]error)
+	pending := names
+	for len(pending) > 0 {
+		owned := make(map[string]*sharedFetch)
+		waiting := make(map[string]*sharedFetch)
+		for _, name := range pending {
+			if fetch, owner := coordinator.claim(sm.fetchKey(name)); owner {
+				owned[name] = fetch
+			} else {
+				waiting[name] = fetch
+			}
+		}
+
+		if len(owned) > 0 {
+			values, fetchErrs := sm.fetchSecrets(ctx, slices.Collect(maps.Keys(owned)))
+			for name, fetch := range owned {
+				coordinator.complete(ctx, sm.fetchKey(name), fetch, values[name], sm.SecretVersion(name), fetchErrs[name])
+				if fetchErrs[name] != nil {
+					errs[name] = fetchErrs[name]
+				} else {
+					secrets[name] = values[name]
+				}
+			}
+		}
+
+		// Secrets fetched by another source are shared, unless that source
+		// was interrupted in which case they're fetched again
+		pending = nil
+		for name, fetch := range waiting {
+			value, interrupted, err := fetch.wait(ctx)
+			switch {
+			case interrupted && ctx.Err() == nil:
+				pending = append(pending, name)
+			case err != nil:
+				errs[name] = err
+			default:
+				secrets[name] = value
+				sm.recordVersion(name, fetch.version)
+			}
+		}
+	}
+
+	return secrets, errs
+}
+
+// GetSecrets handles concurrent retrieval of secrets from secrets manager.
+// Secrets are fetched in batches with BatchGetSecretValue, falling back to a
+// GetSecretValue call per secret when the batch API is denied or the source
+// selects a secret version.
+func (sm *SecretsManagerConnector) GetSecrets(ctx context.Context, keys []string) (map[string]string, []error) {
+	if len(keys) == 0 {
+		return map[string]string{}, nil
+	}
+
+	secrets, errsByName := sm.fetchShared(ctx, keys)
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(errsByName)) {
+		errs = append(errs, errsByName[name])
+	}
+	return secrets, errs
+}
+
+func (s *SecretsManagerConnector) ListSecrets(ctx context.Context, prefix string) ([]string, error) {
 	// List secrets that begin with our prefix
 	params := &secretsmanager.ListSecretsInput{
 		Filters: []types.Filter{
@@ -162,9 +503,13 @@ func (s *SecretsManagerConnector) ListSecrets(prefix string) ([]string, error) {
 		},
 	}
 	secretKeys := []string{}
-	paginator := secretsmanager.NewListSecretsPaginator(s.secretsmanagerClient, params)
+	client, err := s.client(ctx)
+	if err != nil {
+		return secretKeys, err
+	}
+	paginator := secretsmanager.NewListSecretsPaginator(client, params)
 	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(context.TODO())
+		output, err := paginator.NextPage(ctx)
 		if err != nil {
 			return secretKeys, err
 		}
@@ -182,6 +527,7 @@ func (s *SecretsManagerConnector) ListSecrets(prefix string) ([]string, error) {
 }
 
 // GetSecret retrieves a single secret value
-func (sm *SecretsManagerConnector) GetSecret(secretName string) (string, error) {
-	return sm.fetchSecretValue(secretName)
+func (sm *SecretsManagerConnector) GetSecret(ctx context.Context, secretName string) (string, error) {
+	secrets, errs := sm.fetchShared(ctx, []string{secretName})
+	return secrets[secretName], errs[secretName]
 }
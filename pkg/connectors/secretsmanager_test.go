@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -14,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
 	"github.com/roverdotcom/snagsby/pkg/config"
 )
 
@@ -21,6 +23,9 @@ import (
 type mockSecretsManagerClient struct {
 	getSecretValueFunc func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
 	listSecretsFunc    func(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
+	// batchGetSecretValueFunc defaults to denying the batch API so secrets
+	// are fetched through getSecretValueFunc
+	batchGetSecretValueFunc func(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
 }
 
 type mockAWSSecretsManagerBehavior func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
@@ -39,6 +44,13 @@ func (m *mockSecretsManagerClient) GetSecretValue(ctx context.Context, params *s
 	return nil, errors.New("mock not implemented")
 }
 
+func (m *mockSecretsManagerClient) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	if m.batchGetSecretValueFunc != nil {
+		return m.batchGetSecretValueFunc(ctx, params, optFns...)
+	}
+	return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "batch access denied"}
+}
+
 func (m *mockSecretsManagerClient) ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
 	if m.listSecretsFunc != nil {
 		return m.listSecretsFunc(ctx, params, optFns...)
@@ -106,6 +118,7 @@ func TestGetSecretWithVersionStage(t *testing.T) {
 			}
 			return &secretsmanager.GetSecretValueOutput{
 				SecretString: aws.String("test-value"),
+				VersionId:    aws.String("a1b2c3"),
 			}, nil
 		},
 	}
@@ -114,7 +127,7 @@ func TestGetSecretWithVersionStage(t *testing.T) {
 	source := &config.Source{URL: sourceURL}
 	sm := &SecretsManagerConnector{source: source, secretsmanagerClient: mockClient}
 
-	result, err := sm.GetSecret("test-secret")
+	result, err := sm.GetSecret(context.Background(), "test-secret")
 
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -125,6 +138,9 @@ func TestGetSecretWithVersionStage(t *testing.T) {
 	if versionStageReceived != "AWSCURRENT" {
 		t.Errorf("Expected version-stage AWSCURRENT, got %s", versionStageReceived)
 	}
+	if version := sm.SecretVersion("test-secret"); version != "a1b2c3" {
+		t.Errorf("Expected the loaded version a1b2c3, got %s", version)
+	}
 }
 
 // TestGetSecretWithVersionID tests that version-id query parameter is passed correctly
@@ -145,7 +161,7 @@ func TestGetSecretWithVersionID(t *testing.T) {
 	source := &config.Source{URL: sourceURL}
 	sm := &SecretsManagerConnector{source: source, secretsmanagerClient: mockClient}
 
-	result, err := sm.GetSecret("test-secret")
+	result, err := sm.GetSecret(context.Background(), "test-secret")
 
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -158,6 +174,131 @@ func TestGetSecretWithVersionID(t *testing.T) {
 	}
 }
 
+// TestGetSecretBinary tests the handling of SecretBinary values
+func TestGetSecretBinary(t *testing.T) {
+	binaryDir := t.TempDir()
+	mockClient := &mockSecretsManagerClient{
+		getSecretValueFunc: func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+			return &secretsmanager.GetSecretValueOutput{
+				SecretBinary: []byte{0x00, 0xff, 'k', 's'},
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name                   string
+		sourceURL              string
+		expected               string
+		expectedErrorSubstring string
+	}{
+		{
+			name:      "base64",
+			sourceURL: "sm://test?binary=base64",
+			expected:  "AP9rcw==",
+		},
+		{
+			name:      "file",
+			sourceURL: "sm://test?binary=file&binary-dir=" + url.QueryEscape(binaryDir),
+			expected:  filepath.Join(binaryDir, "prod_keystore-f4debc1e.jks"),
+		},
+		{
+			name:                   "no binary option",
+			sourceURL:              "sm://test",
+			expectedErrorSubstring: "has a binary value",
+		},
+		{
+			name:                   "invalid binary option",
+			sourceURL:              "sm://test?binary=hex",
+			expectedErrorSubstring: "invalid binary option",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceURL, _ := url.Parse(tt.sourceURL)
+			sm := NewSecretsManagerConnectorWithClient(mockClient, &config.Source{URL: sourceURL})
+
+			value, err := sm.GetSecret(context.Background(), "prod/keystore.jks")
+			if tt.expectedErrorSubstring != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErrorSubstring) {
+					t.Errorf("Expected error containing '%s', got %v", tt.expectedErrorSubstring, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, value)
+			}
+		})
+	}
+
+	contents, err := os.ReadFile(filepath.Join(binaryDir, "prod_keystore-f4debc1e.jks"))
+	if err != nil || string(contents) != "\x00\xffks" {
+		t.Errorf("Expected the binary secret to be written, got %q %v", contents, err)
+	}
+
+	// Names that only differ in replaced characters get their own file
+	sourceURL, _ := url.Parse("sm://test?binary=file&binary-dir=" + url.QueryEscape(binaryDir))
+	sm := NewSecretsManagerConnectorWithClient(mockClient, &config.Source{URL: sourceURL})
+	value, err := sm.GetSecret(context.Background(), "prod_keystore.jks")
+	if expected := filepath.Join(binaryDir, "prod_keystore-72a3146e.jks"); err != nil || value != expected {
+		t.Errorf("Expected %s, got %s %v", expected, value, err)
+	}
+}
+
+// TestGetSecretBinaryUnsafeFiles tests that binary secrets aren't written
+// through planted symlinks or into a default directory other users can access
+func TestGetSecretBinaryUnsafeFiles(t *testing.T) {
+	mockClient := &mockSecretsManagerClient{
+		getSecretValueFunc: func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+			return &secretsmanager.GetSecretValueOutput{
+				SecretBinary: []byte{0x00, 0xff, 'k', 's'},
+			}, nil
+		},
+	}
+
+	binaryDir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("untouched"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(binaryDir, "prod_keystore-f4debc1e.jks")); err != nil {
+		t.Fatal(err)
+	}
+	sourceURL, _ := url.Parse("sm://test?binary=file&binary-dir=" + url.QueryEscape(binaryDir))
+	sm := NewSecretsManagerConnectorWithClient(mockClient, &config.Source{URL: sourceURL})
+	if _, err := sm.GetSecret(context.Background(), "prod/keystore.jks"); err == nil || !strings.Contains(err.Error(), "isn't a regular file") {
+		t.Errorf("Expected the symlink to be refused, got %v", err)
+	}
+	if contents, _ := os.ReadFile(target); string(contents) != "untouched" {
+		t.Errorf("Expected the symlink target to be untouched, got %q", contents)
+	}
+
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	if err := os.Mkdir(filepath.Join(tmpDir, "snagsby"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(tmpDir, "snagsby"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	sourceURL, _ = url.Parse("sm://test?binary=file")
+	sm = NewSecretsManagerConnectorWithClient(mockClient, &config.Source{URL: sourceURL})
+	if _, err := sm.GetSecret(context.Background(), "prod/keystore.jks"); err == nil || !strings.Contains(err.Error(), "mode 0700") {
+		t.Errorf("Expected the shared directory to be refused, got %v", err)
+	}
+
+	if err := os.Chmod(filepath.Join(tmpDir, "snagsby"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	value, err := sm.GetSecret(context.Background(), "prod/keystore.jks")
+	if expected := filepath.Join(tmpDir, "snagsby", "prod_keystore-f4debc1e.jks"); err != nil || value != expected {
+		t.Errorf("Expected %s, got %s %v", expected, value, err)
+	}
+}
+
 // TestGetSecretErrors tests error handling in GetSecret
 func TestGetSecretErrors(t *testing.T) {
 	tests := []struct {
@@ -184,7 +325,7 @@ func TestGetSecretErrors(t *testing.T) {
 			}
 			sm := GetMockSecretsManagerConnectorWithMocks(mockClient)
 
-			_, err := sm.GetSecret("test-secret")
+			_, err := sm.GetSecret(context.Background(), "test-secret")
 
 			if err == nil {
 				t.Error("Expected error but got none")
@@ -252,7 +393,7 @@ func TestGetSecrets(t *testing.T) {
 				getSecretValueFunc: tt.mockBehavior,
 			}
 			sm := GetMockSecretsManagerConnectorWithMocks(mockClient)
-			result, errors := sm.GetSecrets(tt.keys)
+			result, errors := sm.GetSecrets(context.Background(), tt.keys)
 
 			if len(result) != tt.expectedItems {
 				t.Errorf("Expected %d items, got %d", tt.expectedItems, len(result))
@@ -269,6 +410,129 @@ func TestGetSecrets(t *testing.T) {
 	}
 }
 
+// TestGetSecretsBatch tests that secrets are fetched with BatchGetSecretValue
+// in batches and that per-secret errors are returned
+func TestGetSecretsBatch(t *testing.T) {
+	keys := make([]string, 45)
+	for i := range keys {
+		keys[i] = "secret" + strconv.Itoa(i)
+	}
+
+	var batchCalls int32
+	mockClient := &mockSecretsManagerClient{
+		batchGetSecretValueFunc: func(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+			atomic.AddInt32(&batchCalls, 1)
+			if len(params.SecretIdList) > 20 {
+				t.Errorf("Expected at most 20 secrets per batch, got %d", len(params.SecretIdList))
+			}
+			output := &secretsmanager.BatchGetSecretValueOutput{}
+			for _, id := range params.SecretIdList {
+				if id == "secret7" {
+					output.Errors = append(output.Errors, types.APIErrorType{
+						SecretId:  aws.String(id),
+						ErrorCode: aws.String("ResourceNotFoundException"),
+						Message:   aws.String("Secrets Manager can't find the specified secret."),
+					})
+					continue
+				}
+				output.SecretValues = append(output.SecretValues, types.SecretValueEntry{
+					Name:         aws.String(id),
+					SecretString: aws.String("value-" + id),
+					VersionId:    aws.String("version-" + id),
+				})
+			}
+			return output, nil
+		},
+		getSecretValueFunc: func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+			t.Errorf("Unexpected GetSecretValue call for %s", aws.ToString(params.SecretId))
+			return nil, errors.New("unexpected call")
+		},
+	}
+
+	sm := GetMockSecretsManagerConnectorWithMocks(mockClient)
+	secrets, errs := sm.GetSecrets(context.Background(), keys)
+
+	if batchCalls != 3 {
+		t.Errorf("Expected 3 BatchGetSecretValue calls, got %d", batchCalls)
+	}
+	if len(secrets) != 44 {
+		t.Errorf("Expected 44 secrets, got %d", len(secrets))
+	}
+	if secrets["secret12"] != "value-secret12" {
+		t.Errorf("Expected value-secret12, got %s", secrets["secret12"])
+	}
+	if version := sm.SecretVersion("secret12"); version != "version-secret12" {
+		t.Errorf("Expected version-secret12, got %s", version)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"secret7": api error ResourceNotFoundException`) {
+		t.Errorf("Expected a single ResourceNotFoundException error for secret7, got %v", errs)
+	}
+}
+
+// TestGetSecretsBatchFallback tests when secrets are fetched one at a time
+// instead of in batches
+func TestGetSecretsBatchFallback(t *testing.T) {
+	tests := []struct {
+		name               string
+		sourceURL          string
+		batchErr           error
+		expectedBatchCalls int32
+		expectedErrors     int
+	}{
+		{
+			name:               "batch access denied",
+			sourceURL:          "sm://test",
+			batchErr:           &smithy.GenericAPIError{Code: "AccessDeniedException"},
+			expectedBatchCalls: 1,
+		},
+		{
+			name:               "version stage requested",
+			sourceURL:          "sm://test?version-stage=AWSPREVIOUS",
+			expectedBatchCalls: 0,
+		},
+		{
+			name:               "batch call fails",
+			sourceURL:          "sm://test",
+			batchErr:           errors.New("throttled"),
+			expectedBatchCalls: 2,
+			expectedErrors:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batchCalls int32
+			mockClient := &mockSecretsManagerClient{
+				batchGetSecretValueFunc: func(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+					atomic.AddInt32(&batchCalls, 1)
+					return nil, tt.batchErr
+				},
+				getSecretValueFunc: makeBehavior(func(secretId string) (string, error) {
+					return "value", nil
+				}),
+			}
+
+			sourceURL, _ := url.Parse(tt.sourceURL)
+			sm := NewSecretsManagerConnectorWithClient(mockClient, &config.Source{URL: sourceURL})
+
+			// Denied batches aren't retried on later calls
+			for _, keys := range [][]string{{"one"}, {"two"}} {
+				secrets, errs := sm.GetSecrets(context.Background(), keys)
+				if tt.expectedErrors == 0 && (len(errs) != 0 || secrets[keys[0]] != "value") {
+					t.Errorf("Expected %s to be fetched, got %v %v", keys[0], secrets, errs)
+				}
+				if tt.expectedErrors > 0 && (len(errs) != 1 || !strings.Contains(errs[0].Error(), "throttled")) {
+					t.Errorf("Expected a throttled error for %s, got %v", keys[0], errs)
+				}
+			}
+
+			if batchCalls != tt.expectedBatchCalls {
+				t.Errorf("Expected %d BatchGetSecretValue calls, got %d", tt.expectedBatchCalls, batchCalls)
+			}
+		})
+	}
+}
+
 // TestGetConcurrencyOrDefault tests the GetConcurrencyOrDefault function
 func TestGetConcurrencyOrDefault(t *testing.T) {
 	tests := []struct {
@@ -447,7 +711,7 @@ func TestGetSecretsConcurrency(t *testing.T) {
 			}
 
 			sm := GetMockSecretsManagerConnectorWithMocks(mockClient)
-			result, errors := sm.GetSecrets(keys)
+			result, errors := sm.GetSecrets(context.Background(), keys)
 
 			// Verify all secrets were retrieved
 			if len(result) != tt.numKeys {
@@ -0,0 +1,192 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/roverdotcom/snagsby/pkg/clients"
+	"github.com/roverdotcom/snagsby/pkg/config"
+)
+
+type GetParameterAPIClient interface {
+	GetParameter(context.Context, *ssm.GetParameterInput, ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+type GetParametersAPIClient interface {
+	GetParameters(context.Context, *ssm.GetParametersInput, ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+}
+
+type SSMAPIClient interface {
+	GetParameterAPIClient
+	GetParametersAPIClient
+	ssm.GetParametersByPathAPIClient
+}
+
+// SSMConnector provides methods for retrieving parameters from AWS Systems Manager Parameter Store.
+// The struct fields are private to prevent direct instantiation outside this package.
+// Use NewSSMConnector to create instances.
+type SSMConnector struct {
+	ssmClient  SSMAPIClient
+	source     *config.Source
+	clientOnce sync.Once
+	clientErr  error
+	// versions holds the version of each parameter value fetched
+	versionsMu sync.Mutex
+	versions   map[string]string
+}
+
+// NewSSMConnector returns a connector for source, its AWS client is only
+// created once the connector is first used
+func NewSSMConnector(source *config.Source) *SSMConnector {
+	return &SSMConnector{source: source}
+}
+
+// NewSSMConnectorWithClient creates a new SSMConnector with a custom API client.
+// This is primarily used for testing to inject a mock client. Production code should use NewSSMConnector instead.
+func NewSSMConnectorWithClient(client SSMAPIClient, source *config.Source) *SSMConnector {
+	return &SSMConnector{ssmClient: client, source: source}
+}
+
+// client returns the API client, creating it on first use
+func (s *SSMConnector) client(ctx context.Context) (SSMAPIClient, error) {
+	s.clientOnce.Do(func() {
+		if s.ssmClient != nil {
+			return
+		}
+		client, err := clients.NewSSMClient(ctx, s.source.URL)
+		if err != nil {
+			s.clientErr = fmt.Errorf("creating ssm client: %w", err)
+			return
+		}
+		s.ssmClient = client
+	})
+	return s.ssmClient, s.clientErr
+}
+
+// recordVersion stores the version of the value fetched for a parameter
+func (s *SSMConnector) recordVersion(name string, version int64) {
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+	if s.versions == nil {
+		s.versions = make(map[string]string)
+	}
+	s.versions[name] = strconv.FormatInt(version, 10)
+}
+
+// ParameterVersion returns the version of the value fetched for a parameter,
+// or an empty string when the parameter hasn't been fetched
+func (s *SSMConnector) ParameterVersion(name string) string {
+	s.versionsMu.Lock()
+	defer s.versionsMu.Unlock()
+	return s.versions[name]
+}
+
+// GetParameter retrieves a single parameter value, decrypting SecureString parameters
+func (s *SSMConnector) GetParameter(ctx context.Context, name string) (string, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("fetching parameter %q: %w", name, err)
+	}
+
+	if output.Parameter == nil {
+		return "", fmt.Errorf("parameter %s has no value", name)
+	}
+	s.recordVersion(name, output.Parameter.Version)
+
+	return aws.ToString(output.Parameter.Value), nil
+}
+
+// getParametersBatchSize is the maximum number of names accepted by a single GetParameters call
+const getParametersBatchSize = 10
+
+// GetParameters retrieves multiple parameters by name in batches, decrypting SecureString parameters.
+// Names that cannot be found are reported as errors.
+func (s *SSMConnector) GetParameters(ctx context.Context, names []string) (map[string]string, []error) {
+	parameters := make(map[string]string)
+	var errors []error
+	if len(names) == 0 {
+		return parameters, errors
+	}
+
+	client, err := s.client(ctx)
+	if err != nil {
+		for _, name := range names {
+			errors = append(errors, fmt.Errorf("fetching parameter %q: %w", name, err))
+		}
+		return parameters, errors
+	}
+
+	for start := 0; start < len(names); start += getParametersBatchSize {
+		batch := names[start:min(start+getParametersBatchSize, len(names))]
+
+		output, err := client.GetParameters(ctx, &ssm.GetParametersInput{
+			Names:          batch,
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			for _, name := range batch {
+				errors = append(errors, fmt.Errorf("fetching parameter %q: %w", name, err))
+			}
+			continue
+		}
+
+		for _, parameter := range output.Parameters {
+			parameters[aws.ToString(parameter.Name)] = aws.ToString(parameter.Value)
+			s.recordVersion(aws.ToString(parameter.Name), parameter.Version)
+		}
+		for _, name := range output.InvalidParameters {
+			errors = append(errors, fmt.Errorf("parameter %q not found", name))
+		}
+	}
+
+	return parameters, errors
+}
+
+// GetParametersByPath retrieves every parameter below path, decrypting SecureString parameters.
+// The returned map is keyed by the full parameter name.
+func (s *SSMConnector) GetParametersByPath(ctx context.Context, path string) (map[string]string, error) {
+	// Parameter Store rejects hierarchy paths with a trailing slash, other than the root
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	params := &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}
+	parameters := map[string]string{}
+	client, err := s.client(ctx)
+	if err != nil {
+		return parameters, err
+	}
+	paginator := ssm.NewGetParametersByPathPaginator(client, params)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return parameters, fmt.Errorf("listing parameters under %q: %w", path, err)
+		}
+		for _, parameter := range output.Parameters {
+			name := aws.ToString(parameter.Name)
+			if name == "" {
+				continue
+			}
+			parameters[name] = aws.ToString(parameter.Value)
+			s.recordVersion(name, parameter.Version)
+		}
+	}
+
+	return parameters, nil
+}
@@ -0,0 +1,192 @@
+package connectors_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/connectors"
+	connectortesting "github.com/roverdotcom/snagsby/pkg/connectors/testing"
+)
+
+func getMockSSMConnector(mock *connectortesting.MockSSMAPIClient) *connectors.SSMConnector {
+	sourceURL, _ := url.Parse("ssm:///test")
+	return connectors.NewSSMConnectorWithClient(mock, &config.Source{URL: sourceURL})
+}
+
+func TestGetParameter(t *testing.T) {
+	var decryptionRequested bool
+	mockClient := &connectortesting.MockSSMAPIClient{
+		GetParameterFunc: func(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+			decryptionRequested = aws.ToBool(params.WithDecryption)
+			if aws.ToString(params.Name) != "/prod/db/password" {
+				t.Errorf("Expected name /prod/db/password, got %s", aws.ToString(params.Name))
+			}
+			return &ssm.GetParameterOutput{
+				Parameter: &types.Parameter{
+					Name:    params.Name,
+					Type:    types.ParameterTypeSecureString,
+					Value:   aws.String("decrypted"),
+					Version: 4,
+				},
+			}, nil
+		},
+	}
+
+	connector := getMockSSMConnector(mockClient)
+	value, err := connector.GetParameter(context.Background(), "/prod/db/password")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if value != "decrypted" {
+		t.Errorf("Expected 'decrypted', got %s", value)
+	}
+	if !decryptionRequested {
+		t.Error("Expected WithDecryption to be set")
+	}
+	if version := connector.ParameterVersion("/prod/db/password"); version != "4" {
+		t.Errorf("Expected version 4, got %s", version)
+	}
+}
+
+func TestGetParameterErrors(t *testing.T) {
+	tests := []struct {
+		name                   string
+		output                 *ssm.GetParameterOutput
+		err                    error
+		expectedErrorSubstring string
+	}{
+		{
+			name:                   "parameter not found",
+			err:                    errors.New("ParameterNotFound"),
+			expectedErrorSubstring: "ParameterNotFound",
+		},
+		{
+			name:                   "empty parameter",
+			output:                 &ssm.GetParameterOutput{},
+			expectedErrorSubstring: "has no value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &connectortesting.MockSSMAPIClient{
+				GetParameterFunc: func(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+					return tt.output, tt.err
+				},
+			}
+
+			_, err := getMockSSMConnector(mockClient).GetParameter(context.Background(), "/missing")
+			if err == nil {
+				t.Error("Expected error but got none")
+			} else if !strings.Contains(err.Error(), tt.expectedErrorSubstring) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErrorSubstring, err.Error())
+			}
+		})
+	}
+}
+
+func TestGetParameters(t *testing.T) {
+	names := make([]string, 25)
+	for i := range names {
+		names[i] = fmt.Sprintf("/prod/app/param-%d", i)
+	}
+
+	var calls int
+	mockClient := &connectortesting.MockSSMAPIClient{
+		GetParametersFunc: func(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+			calls++
+			if len(params.Names) > 10 {
+				t.Errorf("Expected at most 10 names per call, got %d", len(params.Names))
+			}
+			output := &ssm.GetParametersOutput{}
+			for _, name := range params.Names {
+				if name == "/prod/app/param-3" {
+					output.InvalidParameters = append(output.InvalidParameters, name)
+					continue
+				}
+				output.Parameters = append(output.Parameters, types.Parameter{Name: aws.String(name), Value: aws.String("value")})
+			}
+			return output, nil
+		},
+	}
+
+	parameters, errs := getMockSSMConnector(mockClient).GetParameters(context.Background(), names)
+	if calls != 3 {
+		t.Errorf("Expected 3 GetParameters calls, got %d", calls)
+	}
+	if len(parameters) != 24 {
+		t.Errorf("Expected 24 parameters, got %d", len(parameters))
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "/prod/app/param-3") {
+		t.Errorf("Expected a single error for /prod/app/param-3, got %v", errs)
+	}
+}
+
+func TestGetParametersByPath(t *testing.T) {
+	pages := map[string]*ssm.GetParametersByPathOutput{
+		"": {
+			Parameters: []types.Parameter{
+				{Name: aws.String("/prod/app/one"), Value: aws.String("1")},
+				{Name: aws.String("/prod/app/nested/two"), Value: aws.String("2")},
+			},
+			NextToken: aws.String("page-2"),
+		},
+		"page-2": {
+			Parameters: []types.Parameter{
+				{Name: aws.String("/prod/app/three"), Value: aws.String("3")},
+			},
+		},
+	}
+
+	mockClient := &connectortesting.MockSSMAPIClient{
+		GetParametersByPathFunc: func(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+			if aws.ToString(params.Path) != "/prod/app" {
+				t.Errorf("Expected path /prod/app, got %s", aws.ToString(params.Path))
+			}
+			if !aws.ToBool(params.Recursive) || !aws.ToBool(params.WithDecryption) {
+				t.Error("Expected Recursive and WithDecryption to be set")
+			}
+			return pages[aws.ToString(params.NextToken)], nil
+		},
+	}
+
+	parameters, err := getMockSSMConnector(mockClient).GetParametersByPath(context.Background(), "/prod/app/")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"/prod/app/one":        "1",
+		"/prod/app/nested/two": "2",
+		"/prod/app/three":      "3",
+	}
+	if len(parameters) != len(expected) {
+		t.Errorf("Expected %d parameters, got %d", len(expected), len(parameters))
+	}
+	for name, value := range expected {
+		if parameters[name] != value {
+			t.Errorf("Expected %s=%s, got %s", name, value, parameters[name])
+		}
+	}
+}
+
+func TestGetParametersByPathError(t *testing.T) {
+	mockClient := &connectortesting.MockSSMAPIClient{
+		GetParametersByPathFunc: func(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+			return nil, errors.New("access denied")
+		},
+	}
+
+	_, err := getMockSSMConnector(mockClient).GetParametersByPath(context.Background(), "/prod/app/")
+	if err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("Expected access denied error, got %v", err)
+	}
+}
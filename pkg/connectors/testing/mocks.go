@@ -3,10 +3,15 @@ package testing
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
 	"github.com/roverdotcom/snagsby/pkg/config"
 	"github.com/roverdotcom/snagsby/pkg/connectors"
 )
@@ -31,10 +36,12 @@ type MockSecretsConnector struct {
 	GetSecretsFunc  func(keys []string) (map[string]string, []error)
 	GetSecretFunc   func(secretName string) (string, error)
 	ListSecretsFunc func(prefix string) ([]string, error)
+	// SecretVersions maps secret names to the version IDs reported for them
+	SecretVersions map[string]string
 }
 
 // GetSecrets retrieves multiple secrets by their keys.
-func (m *MockSecretsConnector) GetSecrets(keys []string) (map[string]string, []error) {
+func (m *MockSecretsConnector) GetSecrets(ctx context.Context, keys []string) (map[string]string, []error) {
 	if m.GetSecretsFunc != nil {
 		return m.GetSecretsFunc(keys)
 	}
@@ -42,21 +49,46 @@ func (m *MockSecretsConnector) GetSecrets(keys []string) (map[string]string, []e
 }
 
 // GetSecret retrieves a single secret by name.
-func (m *MockSecretsConnector) GetSecret(secretName string) (string, error) {
+func (m *MockSecretsConnector) GetSecret(ctx context.Context, secretName string) (string, error) {
 	if m.GetSecretFunc != nil {
 		return m.GetSecretFunc(secretName)
 	}
 	return "", nil
 }
 
+// SecretVersion returns the version ID reported for a secret.
+func (m *MockSecretsConnector) SecretVersion(secretName string) string {
+	return m.SecretVersions[secretName]
+}
+
 // ListSecrets lists all secrets with the given prefix.
-func (m *MockSecretsConnector) ListSecrets(prefix string) ([]string, error) {
+func (m *MockSecretsConnector) ListSecrets(ctx context.Context, prefix string) ([]string, error) {
 	if m.ListSecretsFunc != nil {
 		return m.ListSecretsFunc(prefix)
 	}
 	return []string{}, nil
 }
 
+// MockParametersConnector is a reusable mock for connectors that retrieve Parameter Store values.
+type MockParametersConnector struct {
+	GetParametersFunc func(names []string) (map[string]string, []error)
+	// ParameterVersions maps parameter names to the versions reported for them
+	ParameterVersions map[string]string
+}
+
+// GetParameters retrieves multiple parameters by their names.
+func (m *MockParametersConnector) GetParameters(ctx context.Context, names []string) (map[string]string, []error) {
+	if m.GetParametersFunc != nil {
+		return m.GetParametersFunc(names)
+	}
+	return map[string]string{}, nil
+}
+
+// ParameterVersion returns the version reported for a parameter.
+func (m *MockParametersConnector) ParameterVersion(name string) string {
+	return m.ParameterVersions[name]
+}
+
 // MockSecretsManagerAPIClient is a mock implementation of the AWS Secrets Manager API client.
 // This allows testing the full integration of Resolver + Connector with a mocked AWS SDK client.
 //
@@ -76,6 +108,11 @@ type MockSecretsManagerAPIClient struct {
 
 	// ListSecretsFunc allows custom behavior for ListSecrets
 	ListSecretsFunc func(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
+
+	// BatchGetSecretValueFunc allows custom behavior for BatchGetSecretValue.
+	// When unset and GetSecretValueFunc is set the batch API is denied so
+	// secrets are fetched through GetSecretValueFunc.
+	BatchGetSecretValueFunc func(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
 }
 
 // GetSecretValue implements the GetSecretValueAPIClient interface.
@@ -99,6 +136,34 @@ func (m *MockSecretsManagerAPIClient) GetSecretValue(ctx context.Context, params
 	}, nil
 }
 
+// BatchGetSecretValue implements the BatchGetSecretValueAPIClient interface.
+func (m *MockSecretsManagerAPIClient) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	if m.BatchGetSecretValueFunc != nil {
+		return m.BatchGetSecretValueFunc(ctx, params, optFns...)
+	}
+	if m.GetSecretValueFunc != nil {
+		return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "batch access denied"}
+	}
+
+	output := &secretsmanager.BatchGetSecretValueOutput{}
+	for _, secretName := range params.SecretIdList {
+		value, exists := m.Secrets[secretName]
+		if !exists {
+			output.Errors = append(output.Errors, types.APIErrorType{
+				SecretId:  aws.String(secretName),
+				ErrorCode: aws.String("ResourceNotFoundException"),
+				Message:   aws.String(fmt.Sprintf("secret %s not found", secretName)),
+			})
+			continue
+		}
+		output.SecretValues = append(output.SecretValues, types.SecretValueEntry{
+			Name:         aws.String(secretName),
+			SecretString: aws.String(value),
+		})
+	}
+	return output, nil
+}
+
 // ListSecrets implements the ListSecretsAPIClient interface.
 func (m *MockSecretsManagerAPIClient) ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
 	if m.ListSecretsFunc != nil {
@@ -127,3 +192,119 @@ func NewSecretsManagerConnectorWithFakeSecrets(secrets map[string]string, source
 	}
 	return connectors.NewSecretsManagerConnectorWithClient(mockClient, source)
 }
+
+// MockSSMAPIClient is a mock implementation of the AWS Systems Manager Parameter Store API client.
+// This allows testing the full integration of Resolver + Connector with a mocked AWS SDK client.
+//
+// Usage example:
+//
+//	mock := &testing.MockSSMAPIClient{
+//		Parameters: map[string]string{
+//			"/path/to/parameter": "parameter-value",
+//		},
+//	}
+type MockSSMAPIClient struct {
+	// Parameters maps parameter names to their (decrypted) values
+	Parameters map[string]string
+
+	// GetParameterFunc allows custom behavior for GetParameter
+	GetParameterFunc func(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+
+	// GetParametersFunc allows custom behavior for GetParameters
+	GetParametersFunc func(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+
+	// GetParametersByPathFunc allows custom behavior for GetParametersByPath
+	GetParametersByPathFunc func(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+}
+
+// GetParameter implements the GetParameterAPIClient interface.
+func (m *MockSSMAPIClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	if m.GetParameterFunc != nil {
+		return m.GetParameterFunc(ctx, params, optFns...)
+	}
+
+	name := aws.ToString(params.Name)
+	value, exists := m.Parameters[name]
+	if !exists {
+		return nil, &smithy.GenericAPIError{Code: "ParameterNotFound", Message: fmt.Sprintf("parameter %s not found", name)}
+	}
+
+	return &ssm.GetParameterOutput{
+		Parameter: &ssmtypes.Parameter{
+			Name:    aws.String(name),
+			Value:   aws.String(value),
+			Version: 1,
+		},
+	}, nil
+}
+
+// GetParameters implements the GetParametersAPIClient interface.
+// Names missing from Parameters are reported as InvalidParameters.
+func (m *MockSSMAPIClient) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	if m.GetParametersFunc != nil {
+		return m.GetParametersFunc(ctx, params, optFns...)
+	}
+
+	output := &ssm.GetParametersOutput{}
+	for _, name := range params.Names {
+		value, exists := m.Parameters[name]
+		if !exists {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+			continue
+		}
+		output.Parameters = append(output.Parameters, ssmtypes.Parameter{
+			Name:    aws.String(name),
+			Value:   aws.String(value),
+			Version: 1,
+		})
+	}
+
+	return output, nil
+}
+
+// GetParametersByPath implements the ssm.GetParametersByPathAPIClient interface.
+// All parameters below the requested path are returned in a single page.
+func (m *MockSSMAPIClient) GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	if m.GetParametersByPathFunc != nil {
+		return m.GetParametersByPathFunc(ctx, params, optFns...)
+	}
+
+	prefix := strings.TrimSuffix(aws.ToString(params.Path), "/") + "/"
+	names := []string{}
+	for name := range m.Parameters {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	parameters := []ssmtypes.Parameter{}
+	for _, name := range names {
+		parameters = append(parameters, ssmtypes.Parameter{
+			Name:    aws.String(name),
+			Value:   aws.String(m.Parameters[name]),
+			Version: 1,
+		})
+	}
+
+	return &ssm.GetParametersByPathOutput{
+		Parameters: parameters,
+	}, nil
+}
+
+// NewSSMConnectorWithFakeParameters creates an SSMConnector with fake parameters for testing.
+//
+// Usage example:
+//
+//	connector := testing.NewSSMConnectorWithFakeParameters(
+//		map[string]string{
+//			"/path/to/parameter": "parameter-value",
+//		},
+//		source,
+//	)
+func NewSSMConnectorWithFakeParameters(parameters map[string]string, source *config.Source) *connectors.SSMConnector {
+	mockClient := &MockSSMAPIClient{
+		Parameters: parameters,
+	}
+	return connectors.NewSSMConnectorWithClient(mockClient, source)
+}
@@ -0,0 +1,94 @@
+package parsers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expand replaces the ${VAR} and ${VAR:-default} references in input with the
+// values returned by lookup. The default, which may hold references itself,
+// is used when the variable isn't set or is empty. A variable that isn't set
+// and has no default is an error, as is a reference that isn't closed. Names
+// follow the POSIX rules for environment variables. $$ writes a literal $ and
+// any other $ that doesn't start a ${ reference is kept as is.
+func Expand(input string, lookup func(name string) (string, bool, error)) (string, error) {
+	var out strings.Builder
+	rest := input
+	for {
+		start := strings.IndexByte(rest, '$')
+		if start == -1 {
+			out.WriteString(rest)
+			return out.String(), nil
+		}
+		out.WriteString(rest[:start])
+		if strings.HasPrefix(rest[start:], "$$") {
+			out.WriteByte('$')
+			rest = rest[start+2:]
+			continue
+		}
+		if !strings.HasPrefix(rest[start:], "${") {
+			out.WriteByte('$')
+			rest = rest[start+1:]
+			continue
+		}
+
+		end := closingBrace(rest, start+2)
+		if end == -1 {
+			return "", fmt.Errorf("unclosed reference %q", rest[start:])
+		}
+		reference := rest[start+2 : end]
+		name, fallback, hasDefault := strings.Cut(reference, ":-")
+		if !isVariableName(name) {
+			return "", fmt.Errorf("invalid reference ${%s}", reference)
+		}
+
+		value, ok, err := lookup(name)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case hasDefault && value == "":
+			if value, err = Expand(fallback, lookup); err != nil {
+				return "", err
+			}
+		case !ok:
+			return "", fmt.Errorf("variable %s is not set", name)
+		}
+		out.WriteString(value)
+		rest = rest[end+1:]
+	}
+}
+
+// closingBrace returns the index of the } closing a reference whose content
+// starts at from, skipping over references nested in its default
+func closingBrace(input string, from int) int {
+	depth := 0
+	for i := from; i < len(input); i++ {
+		switch {
+		case strings.HasPrefix(input[i:], "${"):
+			depth++
+			i++
+		case input[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package parsers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	values := map[string]string{
+		"HOST":  "db.internal",
+		"PORT":  "5432",
+		"EMPTY": "",
+	}
+	lookup := func(name string) (string, bool, error) {
+		if name == "BROKEN" {
+			return "", false, errors.New("broken lookup")
+		}
+		value, ok := values[name]
+		return value, ok, nil
+	}
+
+	tests := []struct {
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{input: "plain value", expected: "plain value"},
+		{input: "${HOST}", expected: "db.internal"},
+		{input: "postgres://${HOST}:${PORT}/app", expected: "postgres://db.internal:5432/app"},
+		{input: "${MISSING:-localhost}", expected: "localhost"},
+		{input: "${EMPTY:-fallback}", expected: "fallback"},
+		{input: "${HOST:-fallback}", expected: "db.internal"},
+		{input: "${MISSING:-}", expected: ""},
+		{input: "${MISSING:-${HOST}:${PORT}}", expected: "db.internal:5432"},
+		{input: "${EMPTY}", expected: ""},
+		{input: "$HOST and $ and $}", expected: "$HOST and $ and $}"},
+		{input: "$${HOST} costs $$5 at ${HOST}", expected: "${HOST} costs $5 at db.internal"},
+		{input: "trailing $", expected: "trailing $"},
+		{input: "${MISSING}", expectError: true},
+		{input: "${HOST", expectError: true},
+		{input: "${1HOST}", expectError: true},
+		{input: "${}", expectError: true},
+		{input: "${BROKEN}", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			actual, err := Expand(tt.input, lookup)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if actual != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
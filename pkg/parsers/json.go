@@ -2,32 +2,156 @@ package parsers
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// JSONOptions controls how values other than strings, numbers and booleans
+// are handled when reading a JSON object
+type JSONOptions struct {
+	// Separator flattens nested objects and arrays into keys joined by the
+	// separator, {"db":{"host":"x"}} becomes DB_HOST with "_". Nested values are
+	// dropped when it is empty.
+	Separator string
+	// EncodeArrays stores arrays as their JSON encoding instead of flattening or
+	// dropping them
+	EncodeArrays bool
+	// ErrorOnNull fails the read when a null value is found instead of dropping it
+	ErrorOnNull bool
+}
+
 func ReadJSONString(input string) (map[string]string, error) {
+	out, _, err := ReadJSONStringWithOptions(input, JSONOptions{})
+	return out, err
+}
+
+// ReadJSONStringWithOptions reads a JSON object into a map with upper case keys.
+// A warning is returned for every key that is dropped.
+func ReadJSONStringWithOptions(input string, options JSONOptions) (map[string]string, []string, error) {
 	var f map[string]any
 	out := map[string]string{}
 	if err := json.Unmarshal([]byte(input), &f); err != nil {
-		return out, err
-	}
-	for k, v := range f {
-		k = strings.ToUpper(k)
-		switch vv := v.(type) {
-		case string:
-			out[k] = vv
-		case float64:
-			out[k] = strconv.FormatFloat(vv, 'f', -1, 64)
-		case bool:
-			var b string
-			if vv {
-				b = "1"
-			} else {
-				b = "0"
+		return out, nil, err
+	}
+	flattener := &jsonFlattener{options: options, out: out}
+	for _, k := range sortedKeys(f) {
+		if err := flattener.add(strings.ToUpper(k), f[k]); err != nil {
+			return out, flattener.warnings, err
+		}
+	}
+	return out, flattener.warnings, nil
+}
+
+type jsonFlattener struct {
+	options  JSONOptions
+	out      map[string]string
+	warnings []string
+}
+
+func (f *jsonFlattener) drop(key, reason string) {
+	f.warnings = append(f.warnings, fmt.Sprintf("dropped key %s: %s", key, reason))
+}
+
+func (f *jsonFlattener) add(key string, v any) error {
+	if value, ok := scalarString(v); ok {
+		f.out[key] = value
+		return nil
+	}
+
+	switch vv := v.(type) {
+	case nil:
+		if f.options.ErrorOnNull {
+			return fmt.Errorf("key %s has a null value", key)
+		}
+		f.drop(key, "null value")
+	case []any:
+		if f.options.EncodeArrays {
+			encoded, err := json.Marshal(vv)
+			if err != nil {
+				return err
+			}
+			f.out[key] = string(encoded)
+			return nil
+		}
+		if f.options.Separator == "" {
+			f.drop(key, "array value")
+			return nil
+		}
+		for i, item := range vv {
+			if err := f.add(key+f.options.Separator+strconv.Itoa(i), item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		if f.options.Separator == "" {
+			f.drop(key, "nested object")
+			return nil
+		}
+		for _, k := range sortedKeys(vv) {
+			if err := f.add(key+f.options.Separator+strings.ToUpper(k), vv[k]); err != nil {
+				return err
 			}
-			out[k] = b
 		}
 	}
-	return out, nil
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scalarString renders a JSON string, number or boolean the way snagsby
+// exports them, booleans become 1 or 0
+func scalarString(v any) (string, bool) {
+	switch vv := v.(type) {
+	case string:
+		return vv, true
+	case float64:
+		return strconv.FormatFloat(vv, 'f', -1, 64), true
+	case bool:
+		if vv {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
+
+// ExtractJSONField returns a single field from a JSON object. Nested fields
+// are addressed with a dot separated path such as conn.host. Objects and arrays
+// are returned as JSON.
+func ExtractJSONField(input, path string) (string, error) {
+	var current any
+	if err := json.Unmarshal([]byte(input), &current); err != nil {
+		return "", err
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("field %q not found", path)
+		}
+		if current, ok = object[part]; !ok {
+			return "", fmt.Errorf("field %q not found", path)
+		}
+	}
+
+	if value, ok := scalarString(current); ok {
+		return value, nil
+	}
+	if current == nil {
+		return "", nil
+	}
+	out, err := json.Marshal(current)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
 }
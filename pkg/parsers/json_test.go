@@ -1,6 +1,7 @@
 package parsers
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -14,3 +15,136 @@ func TestReadJSONString(t *testing.T) {
 		t.Errorf("Failed to parse %s to %s", jsonStr, json)
 	}
 }
+
+func TestReadJSONStringDropsNestedValues(t *testing.T) {
+	jsonStr := `{"hello": "world", "db": {"host": "x"}, "tags": ["a"], "empty": null}`
+	json, err := ReadJSONString(jsonStr)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(json, map[string]string{"HELLO": "world"}) {
+		t.Errorf("Expected only HELLO, got %v", json)
+	}
+}
+
+func TestReadJSONStringWithOptions(t *testing.T) {
+	jsonStr := `{"name": "app", "db": {"host": "x", "port": 5432, "replica": {"host": "y"}}, "hosts": ["a", {"b": true}], "empty": null}`
+	tests := []struct {
+		name             string
+		options          JSONOptions
+		expected         map[string]string
+		expectedWarnings []string
+		expectError      bool
+	}{
+		{
+			name:    "no options drops nested values with warnings",
+			options: JSONOptions{},
+			expected: map[string]string{
+				"NAME": "app",
+			},
+			expectedWarnings: []string{
+				"dropped key DB: nested object",
+				"dropped key EMPTY: null value",
+				"dropped key HOSTS: array value",
+			},
+		},
+		{
+			name:    "flatten objects and arrays",
+			options: JSONOptions{Separator: "_"},
+			expected: map[string]string{
+				"NAME":            "app",
+				"DB_HOST":         "x",
+				"DB_PORT":         "5432",
+				"DB_REPLICA_HOST": "y",
+				"HOSTS_0":         "a",
+				"HOSTS_1_B":       "1",
+			},
+			expectedWarnings: []string{
+				"dropped key EMPTY: null value",
+			},
+		},
+		{
+			name:    "flatten objects and encode arrays",
+			options: JSONOptions{Separator: "__", EncodeArrays: true},
+			expected: map[string]string{
+				"NAME":              "app",
+				"DB__HOST":          "x",
+				"DB__PORT":          "5432",
+				"DB__REPLICA__HOST": "y",
+				"HOSTS":             `["a",{"b":true}]`,
+			},
+			expectedWarnings: []string{
+				"dropped key EMPTY: null value",
+			},
+		},
+		{
+			name:        "error on null",
+			options:     JSONOptions{Separator: "_", ErrorOnNull: true},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, warnings, err := ReadJSONStringWithOptions(jsonStr, tt.options)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(out, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, out)
+			}
+			if !reflect.DeepEqual(warnings, tt.expectedWarnings) {
+				t.Errorf("Expected warnings %v, got %v", tt.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestExtractJSONField(t *testing.T) {
+	jsonStr := `{"username": "admin", "port": 5432, "ssl": true, "conn": {"host": "db.internal", "opts": {"retries": 3}}, "tags": ["a", "b"], "empty": null}`
+	tests := []struct {
+		path        string
+		expected    string
+		expectError bool
+	}{
+		{path: "username", expected: "admin"},
+		{path: "port", expected: "5432"},
+		{path: "ssl", expected: "1"},
+		{path: "conn.host", expected: "db.internal"},
+		{path: "conn.opts.retries", expected: "3"},
+		{path: "conn.opts", expected: `{"retries":3}`},
+		{path: "tags", expected: `["a","b"]`},
+		{path: "empty", expected: ""},
+		{path: "password", expectError: true},
+		{path: "conn.port", expectError: true},
+		{path: "username.first", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, err := ExtractJSONField(jsonStr, tt.path)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error for %s, got %q", tt.path, value)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error for %s: %v", tt.path, err)
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %s to be %q, got %q", tt.path, tt.expected, value)
+			}
+		})
+	}
+
+	if _, err := ExtractJSONField("not json", "field"); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
@@ -0,0 +1,22 @@
+package parsers
+
+import (
+	"sigs.k8s.io/yaml"
+)
+
+// ReadYAMLString reads a YAML mapping into a map with upper case keys
+func ReadYAMLString(input string) (map[string]string, error) {
+	out, _, err := ReadYAMLStringWithOptions(input, JSONOptions{})
+	return out, err
+}
+
+// ReadYAMLStringWithOptions reads a YAML mapping the way
+// ReadJSONStringWithOptions reads a JSON object, so the same values are
+// exported whichever format a config is written in.
+func ReadYAMLStringWithOptions(input string, options JSONOptions) (map[string]string, []string, error) {
+	converted, err := yaml.YAMLToJSON([]byte(input))
+	if err != nil {
+		return map[string]string{}, nil, err
+	}
+	return ReadJSONStringWithOptions(string(converted), options)
+}
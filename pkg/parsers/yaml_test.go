@@ -0,0 +1,74 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadYAMLString(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name:     "scalars",
+			input:    "hello: world\ntest: 12\nbool: false\nquoted: \"true\"\n",
+			expected: map[string]string{"HELLO": "world", "TEST": "12", "BOOL": "0", "QUOTED": "true"},
+		},
+		{
+			name:     "nested values are dropped",
+			input:    "hello: world\ndb:\n  host: x\ntags:\n  - a\nempty:\n",
+			expected: map[string]string{"HELLO": "world"},
+		},
+		{
+			name:     "empty document",
+			input:    "",
+			expected: map[string]string{},
+		},
+		{
+			name:        "not a mapping",
+			input:       "- a\n- b\n",
+			expectError: true,
+		},
+		{
+			name:        "invalid yaml",
+			input:       "hello: [world\n",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ReadYAMLString(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(out, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestReadYAMLStringWithOptions(t *testing.T) {
+	input := "name: app\ndb:\n  host: x\n  port: 5432\nhosts:\n  - a\n  - b\n"
+	out, warnings, err := ReadYAMLStringWithOptions(input, JSONOptions{Separator: "_"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"NAME": "app", "DB_HOST": "x", "DB_PORT": "5432", "HOSTS_0": "a", "HOSTS_1": "b"}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("Expected %v, got %v", expected, out)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+}
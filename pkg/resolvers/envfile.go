@@ -2,31 +2,69 @@ package resolvers
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"maps"
 	"os"
+	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
 
 	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/parsers"
 )
 
 // envVarNameRegexp validates POSIX-compliant environment variable names.
 // Must start with letter or underscore, followed by letters, digits, or underscores.
 var envVarNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
+// referenceSchemes are the value prefixes that are resolved against a remote backend
+var referenceSchemes = []string{"sm", "ssm"}
+
 type envFileSecretsGetter interface {
-	GetSecrets(keys []string) (map[string]string, []error)
+	GetSecrets(ctx context.Context, keys []string) (map[string]string, []error)
+}
+
+type envFileParametersGetter interface {
+	GetParameters(ctx context.Context, names []string) (map[string]string, []error)
 }
 
 type EnvFileResolver struct {
-	connector envFileSecretsGetter
+	connector          envFileSecretsGetter
+	parameterConnector envFileParametersGetter
 }
 
-func NewEnvFileResolver(connector envFileSecretsGetter) *EnvFileResolver {
-	return &EnvFileResolver{connector: connector}
+func NewEnvFileResolver(connector envFileSecretsGetter, parameterConnector envFileParametersGetter) *EnvFileResolver {
+	return &EnvFileResolver{connector: connector, parameterConnector: parameterConnector}
+}
+
+// envReference is a value that points at a remote backend, such as
+// sm://path/to/secret or ssm:///path/to/parameter. An optional fragment
+// selects a single field from a JSON value, sm://prod/rds#conn.host
+type envReference struct {
+	scheme string
+	name   string
+	field  string
+}
+
+// parseEnvReference returns the reference a value points to, if any
+func parseEnvReference(value string) (envReference, bool) {
+	for _, scheme := range referenceSchemes {
+		if name, found := strings.CutPrefix(value, scheme+"://"); found {
+			name, field, _ := strings.Cut(name, "#")
+			return envReference{scheme: scheme, name: name, field: field}, true
+		}
+	}
+	return envReference{}, false
+}
+
+func (r envReference) String() string {
+	if r.field != "" {
+		return r.scheme + "://" + r.name + "#" + r.field
+	}
+	return r.scheme + "://" + r.name
 }
 
 // isValidEnvVarName checks if a key is a valid POSIX environment variable name.
@@ -57,74 +95,250 @@ func getFilePath(source *config.Source) string {
 // Quoted values (using " or ') preserve their content including hashes and whitespace.
 // Returns empty strings for blank lines or comment-only lines (no error).
 func parseEnvLine(line string) (string, string, error) {
+	key, value, _, err := parseEnvAssignment(line)
+	return key, value, err
+}
+
+// parseEnvAssignment parses a line like parseEnvLine and also reports whether
+// the value holds ${VAR} references to interpolate. Single quoted values and
+// dollars escaped in double quoted values never start a reference, in a
+// template the escaped dollars are written as $$ and the $$ of unquoted values
+// as $$$$ so it's kept.
+func parseEnvAssignment(line string) (string, string, bool, error) {
 	trimmedLine := strings.TrimSpace(line)
 	if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
-		return "", "", nil
+		return "", "", false, nil
 	}
 
 	parts := strings.SplitN(line, "=", 2)
 	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid line: %s", line)
+		return "", "", false, fmt.Errorf("invalid line: %s", line)
 	}
 	key := strings.TrimSpace(parts[0])
 	value := strings.TrimSpace(parts[1])
 
+	// Lines of shell sourced files may start with export
+	if name, found := strings.CutPrefix(key, "export"); found && name != "" && strings.TrimLeft(name, " \t") != name {
+		key = strings.TrimSpace(name)
+	}
+
 	if key == "" {
-		return "", "", fmt.Errorf("invalid line: %s (empty key)", line)
+		return "", "", false, fmt.Errorf("invalid line: %s (empty key)", line)
 	}
 
 	if !isValidEnvVarName(key) {
-		return "", "", fmt.Errorf("invalid key '%s': environment variable names must contain only letters, digits, and underscores, and must start with a letter or underscore", key)
-	}
-
-	// Handle quoted values (both single and double quotes)
-	if len(value) >= 1 {
-		firstChar := value[0]
-		if firstChar == '"' || firstChar == '\'' {
-			// Find the closing quote
-			closingQuoteIdx := strings.IndexByte(value[1:], firstChar)
-			if closingQuoteIdx == -1 {
-				// No closing quote found
-				return key, "", fmt.Errorf("invalid line: %s (uneven quotes)", line)
-			}
-			// closingQuoteIdx is relative to value[1:], so actual index is closingQuoteIdx + 1
-			actualClosingIdx := closingQuoteIdx + 1
-			// Extract the value between quotes
-			quotedValue := value[1:actualClosingIdx]
-			// Everything after the closing quote is ignored (including comments)
-			return key, quotedValue, nil
+		return "", "", false, fmt.Errorf("invalid key '%s': environment variable names must contain only letters, digits, and underscores, and must start with a letter or underscore", key)
+	}
+
+	// Double quoted values process escapes and may span several lines, the
+	// closing quote is the first one that isn't escaped
+	if strings.HasPrefix(value, "\"") {
+		quotedValue, template, closed := unquoteDouble(value[1:])
+		if !closed {
+			return key, "", false, fmt.Errorf("invalid line: %s (uneven quotes)", line)
 		}
+		// Everything after the closing quote is ignored (including comments)
+		if template != "" {
+			return key, template, true, nil
+		}
+		return key, quotedValue, false, nil
+	}
+
+	// Single quoted values are kept exactly as written
+	if strings.HasPrefix(value, "'") {
+		// Find the closing quote
+		closingQuoteIdx := strings.IndexByte(value[1:], '\'')
+		if closingQuoteIdx == -1 {
+			// No closing quote found
+			return key, "", false, fmt.Errorf("invalid line: %s (uneven quotes)", line)
+		}
+		// closingQuoteIdx is relative to value[1:], so the value ends just before it
+		return key, value[1 : closingQuoteIdx+1], false, nil
 	}
 
 	// Remove inline comments for unquoted values
 	if idx := strings.Index(value, " #"); idx != -1 {
 		value = strings.TrimSpace(value[:idx])
 	}
-	return key, value, nil
+	if !strings.Contains(value, "${") {
+		return key, value, false, nil
+	}
+	// Unquoted values have no escapes, their $$ is kept as written
+	return key, strings.ReplaceAll(value, "$$", "$$$$"), true, nil
+}
+
+// unquoteDouble reads a double quoted value that starts after its opening
+// quote, processing the \n, \r, \t, \", \\, \$ and \` escapes the way a shell
+// would. Other backslashes are kept as written. When the value has a ${
+// reference that isn't escaped, the value is also returned as a template with
+// its escaped dollars written as $$. It reports whether the closing quote was
+// found.
+func unquoteDouble(value string) (string, string, bool) {
+	var out, template strings.Builder
+	write := func(plain, templated string) {
+		out.WriteString(plain)
+		template.WriteString(templated)
+	}
+
+	expands := false
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '"':
+			if !expands {
+				return out.String(), "", true
+			}
+			return out.String(), template.String(), true
+		case c == '\\' && i+1 < len(value):
+			i++
+			switch escaped := value[i]; escaped {
+			case 'n':
+				write("\n", "\n")
+			case 'r':
+				write("\r", "\r")
+			case 't':
+				write("\t", "\t")
+			case '"', '\\', '`':
+				write(string(escaped), string(escaped))
+			case '$':
+				write("$", "$$")
+			default:
+				write("\\"+string(escaped), "\\"+string(escaped))
+			}
+		case strings.HasPrefix(value[i:], "${"):
+			expands = true
+			write("$", "$")
+		case c == '$':
+			// A literal dollar, escaped in the template like \$ so Expand
+			// doesn't read $$ as one
+			write("$", "$$")
+		default:
+			write(string(c), string(c))
+		}
+	}
+	return out.String(), "", false
+}
+
+// opensMultilineValue indicates whether line assigns a double quoted value
+// that continues on the following lines
+func opensMultilineValue(line string) bool {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return false
+	}
+	_, value, found := strings.Cut(line, "=")
+	value = strings.TrimSpace(value)
+	if !found || !strings.HasPrefix(value, "\"") {
+		return false
+	}
+	_, _, closed := unquoteDouble(value[1:])
+	return !closed
 }
 
 // parsedEnvFile holds the parsed environment variables from a file.
 type parsedEnvFile struct {
 	envVars         map[string]string
 	envVarsOrder    []string
-	needsResolution map[string]string
+	needsResolution map[string]envReference
+	// templates are the values with ${VAR} references, single quoted values
+	// are never expanded
+	templates map[string]bool
 }
 
 // parseEnvFile reads and parses an env file, identifying variables and secrets.
 // Returns parsed data structure with env vars, their order, and secrets needing resolution.
+// There is no file to resolve include directives against, #include lines are
+// treated as comments and @include lines are reported as errors.
 func parseEnvFile(file io.Reader, result *Result) *parsedEnvFile {
-	parsed := &parsedEnvFile{
-		envVars:         make(map[string]string),
-		envVarsOrder:    []string{},
-		needsResolution: map[string]string{},
+	return parseEnvFileAt(file, "", result)
+}
+
+// parseEnvFileAt parses an env file read from path, following its #include
+// and @include directives. Included files are parsed where the directive
+// appears and their keys may be overridden by later lines, duplicates within a
+// single file are still errors.
+func parseEnvFileAt(file io.Reader, path string, result *Result) *parsedEnvFile {
+	parser := &envFileParser{
+		parsed: &parsedEnvFile{
+			envVars:         make(map[string]string),
+			envVarsOrder:    []string{},
+			needsResolution: map[string]envReference{},
+			templates:       map[string]bool{},
+		},
+		result: result,
+	}
+
+	var stack []string
+	if path != "" {
+		stack = []string{filepath.Clean(path)}
+	}
+	parser.parse(file, stack)
+	return parser.parsed
+}
+
+// envFileParser collects the variables of an env file and the files it includes
+type envFileParser struct {
+	parsed *parsedEnvFile
+	result *Result
+}
+
+// includePath returns the path of an #include or @include directive
+func includePath(line string) (string, bool) {
+	trimmedLine := strings.TrimSpace(line)
+	for _, directive := range []string{"#include", "@include"} {
+		rest, found := strings.CutPrefix(trimmedLine, directive)
+		if found && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
+
+// parse reads file, the last path of stack, which holds the chain of files
+// that included it. Errors in included files are prefixed with their path.
+func (p *envFileParser) parse(file io.Reader, stack []string) {
+	// keys set by this file, which can't be set again in it even after an
+	// included file overrode them
+	keys := map[string]bool{}
+	appendError := func(err error) {
+		if len(stack) > 1 {
+			err = fmt.Errorf("%s: %w", stack[len(stack)-1], err)
+		}
+		p.result.AppendError(err)
 	}
 
 	scanner := bufio.NewScanner(file)
+	lineNumber := 0
 	for scanner.Scan() {
+		lineNumber++
+		startLine := lineNumber
 		line := scanner.Text()
-		key, value, err := parseEnvLine(line)
+		firstLine := line
+
+		if path, ok := includePath(line); ok {
+			// Without a file to resolve includes against, such as in S3
+			// objects, #include lines are comments as they always were
+			if len(stack) == 0 && strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			if err := p.include(path, stack); err != nil {
+				appendError(fmt.Errorf("line %d: %w", startLine, err))
+			}
+			continue
+		}
+
+		// A double quoted value continues until its closing quote
+		for opensMultilineValue(line) && scanner.Scan() {
+			lineNumber++
+			line += "\n" + scanner.Text()
+		}
+
+		key, value, template, err := parseEnvAssignment(line)
 		if err != nil {
-			result.AppendError(err)
+			if lineNumber > startLine {
+				// Report the opening line of a value that never closed rather
+				// than everything that followed it
+				_, _, _, err = parseEnvAssignment(firstLine)
+			}
+			appendError(fmt.Errorf("line %d: %w", startLine, err))
 			continue
 		}
 
@@ -132,81 +346,198 @@ func parseEnvFile(file io.Reader, result *Result) *parsedEnvFile {
 			continue
 		}
 
-		// Check for duplicate keys
-		if _, exists := parsed.envVars[key]; exists {
-			result.AppendError(fmt.Errorf("duplicate key '%s' found in env file, duplicate keys are not supported", key))
+		// Check for duplicate keys, keys from included files can be overridden
+		if keys[key] {
+			appendError(fmt.Errorf("line %d: duplicate key '%s' found in env file, duplicate keys are not supported", startLine, key))
 			continue
 		}
+		keys[key] = true
+		if _, exists := p.parsed.envVars[key]; exists {
+			delete(p.parsed.needsResolution, key)
+			delete(p.parsed.templates, key)
+		} else {
+			p.parsed.envVarsOrder = append(p.parsed.envVarsOrder, key)
+		}
 
-		parsed.envVars[key] = value
-		parsed.envVarsOrder = append(parsed.envVarsOrder, key)
+		p.parsed.envVars[key] = value
 
-		// If the value points to sm, we will need to resolve it before we can add it to the result
-		if strings.HasPrefix(value, "sm://") {
-			parsed.needsResolution[key] = strings.TrimPrefix(value, "sm://")
+		// If the value points to a backend, we will need to resolve it before we can add it to the result
+		if reference, ok := parseEnvReference(value); ok {
+			p.parsed.needsResolution[key] = reference
+		} else if template {
+			p.parsed.templates[key] = true
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		result.AppendError(err)
+		appendError(err)
+	}
+}
+
+// include parses the file at path, relative to the including file at the end
+// of stack
+func (p *envFileParser) include(path string, stack []string) error {
+	if path == "" {
+		return fmt.Errorf("include without a path")
+	}
+	if len(stack) == 0 {
+		return fmt.Errorf("include %s: includes are only supported in file:// sources", path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(stack[len(stack)-1]), path)
+	}
+	path = filepath.Clean(path)
+
+	for _, including := range stack {
+		if sameFile(including, path) {
+			return fmt.Errorf("include cycle %s", strings.Join(append(slices.Clone(stack), path), " -> "))
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("include %s: %w", path, err)
 	}
+	defer file.Close()
 
-	return parsed
+	p.parse(file, append(slices.Clone(stack), path))
+	return nil
+}
+
+// sameFile indicates whether two paths refer to the same file, falling back to
+// comparing the absolute paths when either can't be read
+func sameFile(a, b string) bool {
+	aInfo, aErr := os.Stat(a)
+	bInfo, bErr := os.Stat(b)
+	if aErr == nil && bErr == nil {
+		return os.SameFile(aInfo, bInfo)
+	}
+	aAbs, _ := filepath.Abs(a)
+	bAbs, _ := filepath.Abs(b)
+	return aAbs == bAbs
+}
+
+// referenceValue returns the value for a reference from the fetched backend value,
+// extracting a single JSON field when the reference has one.
+func referenceValue(reference envReference, value string) (string, error) {
+	if reference.field == "" {
+		return value, nil
+	}
+	fieldValue, err := parsers.ExtractJSONField(value, reference.field)
+	if err != nil {
+		return "", fmt.Errorf("extracting field from %s://%s: %w", reference.scheme, reference.name, err)
+	}
+	return fieldValue, nil
+}
+
+// appendEnvVar adds a value written in the file to the result, values with
+// ${VAR} references are expanded when the results are interpolated
+func appendEnvVar(parsed *parsedEnvFile, key string, result *Result) {
+	result.AppendItemExact(key, parsed.envVars[key])
+	if parsed.templates[key] {
+		result.SetTemplate(key)
+	}
 }
 
 // populateResultWithSecrets adds environment variables to the result, resolving secrets as needed.
-func populateResultWithSecrets(parsed *parsedEnvFile, secrets map[string]string, result *Result) {
+// Resolved values are looked up by the scheme and then the name of each reference,
+// source is the source the connectors fetch references for.
+func (e *EnvFileResolver) populateResultWithSecrets(source *config.Source, parsed *parsedEnvFile, secrets map[string]map[string]string, result *Result) {
 	for _, key := range parsed.envVarsOrder {
-		secretPath, needsSecret := parsed.needsResolution[key]
+		reference, needsSecret := parsed.needsResolution[key]
 		if needsSecret {
-			if secretValue, found := secrets[secretPath]; found {
-				result.AppendItemExact(key, secretValue)
+			if secretValue, found := secrets[reference.scheme][reference.name]; found {
+				value, err := referenceValue(reference, secretValue)
+				if err != nil {
+					result.AppendError(fmt.Errorf("key '%s': %w", key, err))
+					continue
+				}
+				result.AppendItemExact(key, value)
+				result.SetReference(key, e.loadedReference(reference, source))
 			}
 			// If secret not found, skip it (error already reported during GetSecrets)
 		} else {
-			result.AppendItemExact(key, parsed.envVars[key])
+			appendEnvVar(parsed, key, result)
 		}
 	}
 }
 
-func (e *EnvFileResolver) resolve(file io.Reader, result *Result) {
-	parsed := parseEnvFile(file, result)
+func (e *EnvFileResolver) resolve(ctx context.Context, file io.Reader, result *Result) {
+	e.resolveParsed(ctx, result.Source, parseEnvFile(file, result), result)
+}
+
+// resolveParsed adds the variables of a parsed env file to the result,
+// fetching the values of its references for source
+func (e *EnvFileResolver) resolveParsed(ctx context.Context, source *config.Source, parsed *parsedEnvFile, result *Result) {
 
 	// All lines have explicit values. No need to resolve them.
 	if len(parsed.needsResolution) == 0 {
 		for _, key := range parsed.envVarsOrder {
-			result.AppendItemExact(key, parsed.envVars[key])
+			appendEnvVar(parsed, key, result)
 		}
 		return
 	}
 
-	// The values in the original env file contain the path for secrets manager
-	// Dedupe secret keys to avoid redundant API calls
-	secretKeysMap := make(map[string]bool)
-	for _, secretPath := range parsed.needsResolution {
-		secretKeysMap[secretPath] = true
+	// The values in the original env file contain the path for each backend
+	// Dedupe keys per backend to avoid redundant API calls
+	referenceNames := make(map[string]map[string]bool)
+	for _, reference := range parsed.needsResolution {
+		if referenceNames[reference.scheme] == nil {
+			referenceNames[reference.scheme] = make(map[string]bool)
+		}
+		referenceNames[reference.scheme][reference.name] = true
 	}
-	secretKeys := slices.Collect(maps.Keys(secretKeysMap))
-	secrets, errors := e.connector.GetSecrets(secretKeys)
 
-	for _, err := range errors {
-		result.AppendError(err)
+	secrets := make(map[string]map[string]string)
+	for scheme, names := range referenceNames {
+		values, errors := e.fetchReferences(ctx, scheme, slices.Collect(maps.Keys(names)))
+		for _, err := range errors {
+			result.AppendError(err)
+		}
+		secrets[scheme] = values
+	}
+
+	e.populateResultWithSecrets(source, parsed, secrets, result)
+}
+
+// loadedReference returns where the value of an env file reference was read
+// from, along with the version of the secret or parameter that was loaded
+func (e *EnvFileResolver) loadedReference(reference envReference, source *config.Source) Reference {
+	var loaded Reference
+	switch reference.scheme {
+	case "ssm":
+		loaded = parameterReference(e.parameterConnector, reference.name)
+	default:
+		loaded = secretReference(e.connector, source, reference.name)
 	}
+	loaded.Name = reference.String()
+	return loaded
+}
 
-	populateResultWithSecrets(parsed, secrets, result)
+// fetchReferences retrieves the named values from the backend for scheme
+func (e *EnvFileResolver) fetchReferences(ctx context.Context, scheme string, names []string) (map[string]string, []error) {
+	switch scheme {
+	case "ssm":
+		return e.parameterConnector.GetParameters(ctx, names)
+	default:
+		return e.connector.GetSecrets(ctx, names)
+	}
 }
 
-func (e *EnvFileResolver) Resolve(source *config.Source) *Result {
+func (e *EnvFileResolver) Resolve(ctx context.Context, source *config.Source) *Result {
 	result := &Result{Source: source}
 
 	filePath := getFilePath(source)
 	fileReader, err := os.Open(filePath)
 	if err != nil {
+		if skipMissing(result, err) {
+			return result
+		}
 		result.AppendError(err)
 		return result
 	}
 	defer fileReader.Close()
 
-	e.resolve(fileReader, result)
+	e.resolveParsed(ctx, source, parseEnvFileAt(fileReader, filePath, result), result)
 
 	return result
 }
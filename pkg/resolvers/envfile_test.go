@@ -1,14 +1,21 @@
 package resolvers
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/connectors"
 	connectortesting "github.com/roverdotcom/snagsby/pkg/connectors/testing"
+	"github.com/roverdotcom/snagsby/pkg/formatters"
 )
 
 func TestGetFilePath(t *testing.T) {
@@ -261,7 +268,70 @@ func TestProcessLine(t *testing.T) {
 			name:          "value with newline in quotes",
 			line:          "KEY=\"line1\\nline2\"",
 			expectedKey:   "KEY",
-			expectedValue: "line1\\nline2",
+			expectedValue: "line1\nline2",
+			expectedError: nil,
+		},
+		{
+			name:          "escapes in double quotes",
+			line:          `KEY="say \"hi\"\tthen\\leave \d" # comment`,
+			expectedKey:   "KEY",
+			expectedValue: "say \"hi\"\tthen\\leave \\d",
+			expectedError: nil,
+		},
+		{
+			name:          "escaped quote does not close the value",
+			line:          `KEY="value\"`,
+			expectedKey:   "KEY",
+			expectedValue: "",
+			expectedError: fmt.Errorf(`invalid line: KEY="value\" (uneven quotes)`),
+		},
+		{
+			name:          "escaped dollar and backtick in double quotes",
+			line:          "KEY=\"\\$HOME \\`pwd\\`\"",
+			expectedKey:   "KEY",
+			expectedValue: "$HOME `pwd`",
+			expectedError: nil,
+		},
+		{
+			name:          "export prefix",
+			line:          "export FOO=bar",
+			expectedKey:   "FOO",
+			expectedValue: "bar",
+			expectedError: nil,
+		},
+		{
+			name:          "export prefix with extra whitespace and quotes",
+			line:          "  export\t FOO = \"bar baz\"",
+			expectedKey:   "FOO",
+			expectedValue: "bar baz",
+			expectedError: nil,
+		},
+		{
+			name:          "key starting with export",
+			line:          "exportFOO=bar",
+			expectedKey:   "exportFOO",
+			expectedValue: "bar",
+			expectedError: nil,
+		},
+		{
+			name:          "key named export",
+			line:          "export=bar",
+			expectedKey:   "export",
+			expectedValue: "bar",
+			expectedError: nil,
+		},
+		{
+			name:          "export prefix with invalid key",
+			line:          "export foo-bar=value",
+			expectedKey:   "",
+			expectedValue: "",
+			expectedError: fmt.Errorf("invalid key 'foo-bar': environment variable names must contain only letters, digits, and underscores, and must start with a letter or underscore"),
+		},
+		{
+			name:          "no escapes in single quotes",
+			line:          `KEY='line1\nline2\t'`,
+			expectedKey:   "KEY",
+			expectedValue: `line1\nline2\t`,
 			expectedError: nil,
 		},
 	}
@@ -338,21 +408,21 @@ func TestEnvFileResolve(t *testing.T) {
 			name:                     "duplicate env var should return error",
 			fileContents:             "FOO=bar\nFOO=baz",
 			expectedItems:            map[string]string{"FOO": "bar"},
-			expectedErrors:           []string{"duplicate key 'FOO' found in env file, duplicate keys are not supported"},
+			expectedErrors:           []string{"line 2: duplicate key 'FOO' found in env file, duplicate keys are not supported"},
 			expectedSecretsRequested: []string{},
 		},
 		{
 			name:                     "key with dash should return validation error",
 			fileContents:             "foo-bar=value1",
 			expectedItems:            map[string]string{},
-			expectedErrors:           []string{"invalid key 'foo-bar': environment variable names must contain only letters, digits, and underscores, and must start with a letter or underscore"},
+			expectedErrors:           []string{"line 1: invalid key 'foo-bar': environment variable names must contain only letters, digits, and underscores, and must start with a letter or underscore"},
 			expectedSecretsRequested: []string{},
 		},
 		{
 			name:                     "duplicate keys with same format should error",
 			fileContents:             "MY_KEY=value1\nMY_KEY=value2",
 			expectedItems:            map[string]string{"MY_KEY": "value1"},
-			expectedErrors:           []string{"duplicate key 'MY_KEY' found in env file, duplicate keys are not supported"},
+			expectedErrors:           []string{"line 2: duplicate key 'MY_KEY' found in env file, duplicate keys are not supported"},
 			expectedSecretsRequested: []string{},
 		},
 		{
@@ -371,6 +441,28 @@ QUX=sm://shared/secret`,
 			// Should only request each unique secret path once
 			expectedSecretsRequested: []string{"shared/secret", "other/secret"},
 		},
+		{
+			name: "json fields are extracted from a single fetch of the secret",
+			fileContents: `DB_USER=sm://prod/rds#username
+DB_PASSWORD=sm://prod/rds#password
+DB_HOST=sm://prod/rds#conn.host
+DB_PORT=sm://prod/rds#conn.port`,
+			expectedItems: map[string]string{
+				"DB_USER":     "admin",
+				"DB_PASSWORD": "hunter2",
+				"DB_HOST":     "db.internal",
+				"DB_PORT":     "5432",
+			},
+			expectedErrors:           []string{},
+			expectedSecretsRequested: []string{"prod/rds"},
+		},
+		{
+			name:                     "missing json field returns a per key error",
+			fileContents:             "DB_USER=sm://prod/rds#username\nDB_NAME=sm://prod/rds#conn.name",
+			expectedItems:            map[string]string{"DB_USER": "admin"},
+			expectedErrors:           []string{`key 'DB_NAME': extracting field from sm://prod/rds: field "conn.name" not found`},
+			expectedSecretsRequested: []string{"prod/rds"},
+		},
 		{
 			name:                     "keys are preserved exactly without normalization",
 			fileContents:             "lowercase_var=value1\nMIXED_Case_Var=value2\nUPPERCASE_VAR=value3",
@@ -390,6 +482,8 @@ QUX=sm://shared/secret`,
 			for _, key := range keys {
 				if strings.Contains(key, "not-found") {
 					errors = append(errors, fmt.Errorf("secret not found: sm://%s", key))
+				} else if strings.Contains(key, "rds") {
+					secrets[key] = `{"username":"admin","password":"hunter2","conn":{"host":"db.internal","port":5432}}`
 				} else {
 					// Note: keys come without the "sm://" prefix as the resolver strips it
 					secrets[key] = "resolved-value-for-sm://" + key
@@ -407,7 +501,7 @@ QUX=sm://shared/secret`,
 			envFileResolver := &EnvFileResolver{
 				connector: mockSecretsManagerConnector,
 			}
-			envFileResolver.resolve(strings.NewReader(example.fileContents), result)
+			envFileResolver.resolve(context.Background(), strings.NewReader(example.fileContents), result)
 
 			// Check that the number of items matches
 			if len(result.Items) != len(example.expectedItems) {
@@ -523,7 +617,7 @@ func TestEnvFileIntegrationTest(t *testing.T) {
 	)
 
 	// Create the real EnvFileResolver with the real connector
-	envFileResolver := NewEnvFileResolver(secretsManagerConnector)
+	envFileResolver := NewEnvFileResolver(secretsManagerConnector, &connectortesting.MockParametersConnector{})
 
 	// Create file source for the resolver
 	fileSource := &config.Source{
@@ -534,7 +628,7 @@ func TestEnvFileIntegrationTest(t *testing.T) {
 	}
 
 	// Resolve the env file (this tests the full integration)
-	result := envFileResolver.Resolve(fileSource)
+	result := envFileResolver.Resolve(context.Background(), fileSource)
 
 	// Verify results
 	expectedItems := map[string]string{
@@ -568,6 +662,53 @@ func TestEnvFileIntegrationTest(t *testing.T) {
 	}
 }
 
+// TestEnvFileIntegrationTestWithBinarySecret tests that binary secrets are
+// encoded according to the ?binary= option of the file source
+func TestEnvFileIntegrationTestWithBinarySecret(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "envfile-test-*.env")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString("KEYSTORE=sm://prod/keystore\nPASSWORD=sm://prod/password\n")
+	if err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	fileSource := &config.Source{
+		URL: &url.URL{
+			Scheme:   "file",
+			Path:     tmpFile.Name(),
+			RawQuery: "binary=base64",
+		},
+	}
+
+	mockClient := &connectortesting.MockSecretsManagerAPIClient{
+		GetSecretValueFunc: func(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+			if aws.ToString(params.SecretId) == "prod/keystore" {
+				return &secretsmanager.GetSecretValueOutput{SecretBinary: []byte("keystore")}, nil
+			}
+			return &secretsmanager.GetSecretValueOutput{SecretString: aws.String("hunter2")}, nil
+		},
+	}
+	connector := connectors.NewSecretsManagerConnectorWithClient(mockClient, fileSource)
+
+	result := NewEnvFileResolver(connector, &connectortesting.MockParametersConnector{}).Resolve(context.Background(), fileSource)
+
+	if len(result.Errors) != 0 {
+		t.Errorf("Expected 0 errors but got %d: %v", len(result.Errors), result.Errors)
+	}
+	expectedItems := map[string]string{
+		"KEYSTORE": "a2V5c3RvcmU=",
+		"PASSWORD": "hunter2",
+	}
+	if !reflect.DeepEqual(result.Items, expectedItems) {
+		t.Errorf("Expected %v, got %v", expectedItems, result.Items)
+	}
+}
+
 // TestEnvFileIntegrationTestWithMissingSecret tests the integration when a secret is not found in AWS
 func TestEnvFileIntegrationTestWithMissingSecret(t *testing.T) {
 	// Create a temporary .env file with a reference to a non-existent secret
@@ -603,7 +744,7 @@ TEST=12345
 	)
 
 	// Create the real EnvFileResolver with the real connector
-	envFileResolver := NewEnvFileResolver(secretsManagerConnector)
+	envFileResolver := NewEnvFileResolver(secretsManagerConnector, &connectortesting.MockParametersConnector{})
 
 	// Create file source for the resolver
 	fileSource := &config.Source{
@@ -614,7 +755,7 @@ TEST=12345
 	}
 
 	// Resolve the env file
-	result := envFileResolver.Resolve(fileSource)
+	result := envFileResolver.Resolve(context.Background(), fileSource)
 
 	// Verify that only non-secret items are present
 	expectedItems := map[string]string{
@@ -645,3 +786,272 @@ TEST=12345
 		}
 	}
 }
+
+func TestEnvFileResolveMixedReferences(t *testing.T) {
+	fileContents := `PLAIN=value
+DB_PASSWORD=sm://prod/db/password
+DB_HOST=ssm:///prod/db/host
+DB_HOST_AGAIN=ssm:///prod/db/host
+DB_PORT=ssm:///prod/db/port
+MISSING=ssm:///prod/db/missing
+`
+	requestedSecrets := []string{}
+	secretsConnector := &connectortesting.MockSecretsConnector{
+		GetSecretsFunc: func(keys []string) (map[string]string, []error) {
+			requestedSecrets = append(requestedSecrets, keys...)
+			return map[string]string{"prod/db/password": "hunter2"}, nil
+		},
+		SecretVersions: map[string]string{"prod/db/password": "a1b2c3"},
+	}
+
+	parameterCalls := 0
+	requestedParameters := []string{}
+	parametersConnector := &connectortesting.MockParametersConnector{
+		GetParametersFunc: func(names []string) (map[string]string, []error) {
+			parameterCalls++
+			requestedParameters = append(requestedParameters, names...)
+			return map[string]string{
+				"/prod/db/host": "db.internal",
+				"/prod/db/port": "5432",
+			}, []error{fmt.Errorf("parameter %q not found", "/prod/db/missing")}
+		},
+		ParameterVersions: map[string]string{"/prod/db/host": "3", "/prod/db/port": "1"},
+	}
+
+	result := &Result{}
+	envFileResolver := NewEnvFileResolver(secretsConnector, parametersConnector)
+	envFileResolver.resolve(context.Background(), strings.NewReader(fileContents), result)
+
+	expectedItems := map[string]string{
+		"PLAIN":         "value",
+		"DB_PASSWORD":   "hunter2",
+		"DB_HOST":       "db.internal",
+		"DB_HOST_AGAIN": "db.internal",
+		"DB_PORT":       "5432",
+	}
+	if len(result.Items) != len(expectedItems) {
+		t.Errorf("Expected %d items but got %d: %v", len(expectedItems), len(result.Items), result.Items)
+	}
+	for key, value := range expectedItems {
+		if actualValue := result.Items[key]; actualValue != value {
+			t.Errorf("Expected item %s to have value %s but got %s", key, value, actualValue)
+		}
+	}
+
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "/prod/db/missing") {
+		t.Errorf("Expected a single error for the missing parameter, got %v", result.Errors)
+	}
+
+	expectedReferences := map[string]Reference{
+		"DB_PASSWORD":   {Name: "sm://prod/db/password", Version: "a1b2c3", Stage: "AWSCURRENT"},
+		"DB_HOST":       {Name: "ssm:///prod/db/host", Version: "3"},
+		"DB_HOST_AGAIN": {Name: "ssm:///prod/db/host", Version: "3"},
+		"DB_PORT":       {Name: "ssm:///prod/db/port", Version: "1"},
+	}
+	if !reflect.DeepEqual(result.References, expectedReferences) {
+		t.Errorf("Expected references %v, got %v", expectedReferences, result.References)
+	}
+
+	if len(requestedSecrets) != 1 || requestedSecrets[0] != "prod/db/password" {
+		t.Errorf("Expected only prod/db/password to be requested from secrets manager, got %v", requestedSecrets)
+	}
+
+	// Each backend is batched into a single call with deduped names
+	if parameterCalls != 1 {
+		t.Errorf("Expected 1 GetParameters call but got %d", parameterCalls)
+	}
+	if len(requestedParameters) != 3 {
+		t.Errorf("Expected 3 unique parameters to be requested but got %d: %v", len(requestedParameters), requestedParameters)
+	}
+}
+
+func TestParseEnvFileMultiline(t *testing.T) {
+	fileContents := `# Certificate
+CERT="-----BEGIN CERTIFICATE-----
+MIIBszCCAVmgAwIBAgIUZ
+-----END CERTIFICATE-----" # trailing comment
+AFTER=value
+SINGLE='one\ntwo'
+BAD KEY
+OPEN="never
+closed
+`
+	result := &Result{}
+	parsed := parseEnvFile(strings.NewReader(fileContents), result)
+
+	expected := map[string]string{
+		"CERT":   "-----BEGIN CERTIFICATE-----\nMIIBszCCAVmgAwIBAgIUZ\n-----END CERTIFICATE-----",
+		"AFTER":  "value",
+		"SINGLE": `one\ntwo`,
+	}
+	if !reflect.DeepEqual(parsed.envVars, expected) {
+		t.Errorf("Expected %v, got %v", expected, parsed.envVars)
+	}
+
+	expectedErrors := []string{
+		"line 7: invalid line: BAD KEY",
+		`line 8: invalid line: OPEN="never (uneven quotes)`,
+	}
+	var errors []string
+	for _, err := range result.Errors {
+		errors = append(errors, err.Error())
+	}
+	if !reflect.DeepEqual(errors, expectedErrors) {
+		t.Errorf("Expected errors %v, got %v", expectedErrors, errors)
+	}
+}
+
+func TestEnvFormatterRoundTrip(t *testing.T) {
+	items := map[string]string{
+		"PLAIN":      "value",
+		"EMPTY":      "",
+		"SPACES":     "  padded value  ",
+		"HASH":       "value # not a comment",
+		"QUOTES":     `say "hi" and 'bye'`,
+		"BACKSLASH":  `C:\path\to\n`,
+		"DOLLARS":    "$HOME ${HOST} ${HOST:-default} $$",
+		"BACKTICKS":  "`whoami`",
+		"MULTILINE":  "-----BEGIN KEY-----\nabc\n-----END KEY-----\n",
+		"TAB":        "a\tb",
+		"lower_case": "kept",
+	}
+
+	formats := map[string]func(map[string]string) string{
+		"env":     formatters.EnvFormater,
+		"envfile": formatters.EnvFileFormater,
+	}
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			result := &Result{}
+			parsed := parseEnvFile(strings.NewReader(format(items)), result)
+			if result.HasErrors() {
+				t.Fatalf("Unexpected errors: %v", result.Errors)
+			}
+			if !reflect.DeepEqual(parsed.envVars, items) {
+				t.Errorf("Expected %v, got %v", items, parsed.envVars)
+			}
+			if len(parsed.templates) != 0 || len(parsed.needsResolution) != 0 {
+				t.Errorf("Expected no templates or references, got %v %v", parsed.templates, parsed.needsResolution)
+			}
+		})
+	}
+}
+
+func TestEnvFileIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	writeFile("base.snagsby", "HOST=localhost\nPORT=5432\nPASSWORD=sm://dev/db\n")
+	writeFile("common/logging.snagsby", "#include ../base.snagsby\nLOG_LEVEL=debug\n")
+	production := writeFile("envs/production.snagsby", `@include ../common/logging.snagsby
+HOST=db.internal
+PASSWORD=sm://prod/db
+LOG_LEVEL=info
+`)
+	writeFile("cycle/a.snagsby", "A=1\n#include ./b.snagsby\n")
+	cycle := writeFile("cycle/b.snagsby", "B=1\n#include a.snagsby\n")
+	writeFile("broken/base.snagsby", "GOOD=1\nbad-key=1\n")
+	broken := writeFile("broken/app.snagsby", "#include base.snagsby\n#include missing.snagsby\nAPP=1\nAPP=2\n")
+	writeFile("duplicate/base.snagsby", "A=base\n")
+	duplicate := writeFile("duplicate/app.snagsby", "A=1\n#include base.snagsby\nA=2\n")
+
+	t.Run("overrides included keys", func(t *testing.T) {
+		var requested []string
+		connector := &connectortesting.MockSecretsConnector{
+			GetSecretsFunc: func(keys []string) (map[string]string, []error) {
+				requested = append(requested, keys...)
+				return map[string]string{"prod/db": "hunter2"}, nil
+			},
+		}
+		source := &config.Source{URL: &url.URL{Scheme: "file", Path: production}}
+		result := NewEnvFileResolver(connector, &connectortesting.MockParametersConnector{}).Resolve(context.Background(), source)
+
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+		expected := map[string]string{"HOST": "db.internal", "PORT": "5432", "PASSWORD": "hunter2", "LOG_LEVEL": "info"}
+		if !reflect.DeepEqual(result.Items, expected) {
+			t.Errorf("Expected %v, got %v", expected, result.Items)
+		}
+		// The overridden reference isn't fetched
+		if !reflect.DeepEqual(requested, []string{"prod/db"}) {
+			t.Errorf("Expected only prod/db to be requested, got %v", requested)
+		}
+	})
+
+	t.Run("include cycle", func(t *testing.T) {
+		result := &Result{}
+		file, _ := os.Open(cycle)
+		defer file.Close()
+		parsed := parseEnvFileAt(file, cycle, result)
+
+		if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "include cycle") {
+			t.Errorf("Expected an include cycle error, got %v", result.Errors)
+		}
+		if !reflect.DeepEqual(parsed.envVars, map[string]string{"A": "1", "B": "1"}) {
+			t.Errorf("Expected A and B, got %v", parsed.envVars)
+		}
+	})
+
+	t.Run("errors name the included file", func(t *testing.T) {
+		result := &Result{}
+		file, _ := os.Open(broken)
+		defer file.Close()
+		parseEnvFileAt(file, broken, result)
+
+		var errors []string
+		for _, err := range result.Errors {
+			errors = append(errors, err.Error())
+		}
+		expected := []string{
+			filepath.Join(dir, "broken/base.snagsby") + ": line 2: invalid key 'bad-key': environment variable names must contain only letters, digits, and underscores, and must start with a letter or underscore",
+			"line 2: include " + filepath.Join(dir, "broken/missing.snagsby") + ": open " + filepath.Join(dir, "broken/missing.snagsby") + ": no such file or directory",
+			"line 4: duplicate key 'APP' found in env file, duplicate keys are not supported",
+		}
+		if !reflect.DeepEqual(errors, expected) {
+			t.Errorf("Expected errors %v, got %v", expected, errors)
+		}
+	})
+
+	t.Run("duplicate around an include", func(t *testing.T) {
+		result := &Result{}
+		file, _ := os.Open(duplicate)
+		defer file.Close()
+		parsed := parseEnvFileAt(file, duplicate, result)
+
+		if len(result.Errors) != 1 || result.Errors[0].Error() != "line 3: duplicate key 'A' found in env file, duplicate keys are not supported" {
+			t.Errorf("Expected a duplicate key error, got %v", result.Errors)
+		}
+		if !reflect.DeepEqual(parsed.envVars, map[string]string{"A": "base"}) {
+			t.Errorf("Expected A from the included file, got %v", parsed.envVars)
+		}
+	})
+
+	t.Run("no file to include from", func(t *testing.T) {
+		result := &Result{}
+		parseEnvFile(strings.NewReader("@include base.snagsby\nA=1\n"), result)
+		if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "only supported in file:// sources") {
+			t.Errorf("Expected an include error, got %v", result.Errors)
+		}
+	})
+
+	t.Run("hash include without a file is a comment", func(t *testing.T) {
+		result := &Result{}
+		parsed := parseEnvFile(strings.NewReader("#include base.snagsby\nA=1\n"), result)
+		if len(result.Errors) != 0 {
+			t.Errorf("Unexpected errors: %v", result.Errors)
+		}
+		if !reflect.DeepEqual(parsed.envVars, map[string]string{"A": "1"}) {
+			t.Errorf("Expected only A to be parsed, got %v", parsed.envVars)
+		}
+	})
+}
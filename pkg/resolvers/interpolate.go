@@ -0,0 +1,74 @@
+package resolvers
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/roverdotcom/snagsby/pkg/parsers"
+)
+
+// Interpolate expands the ${VAR} and ${VAR:-default} references in the
+// result's templates. Variables are read from the result's own items, which
+// may be templates themselves, and then from lookup, which returns the values
+// of earlier sources. A template referencing its own key reads it from lookup,
+// so PATH=${PATH}:/opt/bin extends an earlier value. Templates whose keys were
+// namespaced read the items of their object under the same prefix. Templates
+// that can't be expanded, including references that form a cycle, are
+// removed from the items and reported as errors.
+func (r *Result) Interpolate(lookup func(name string) (string, bool)) {
+	expanded := map[string]string{}
+	failed := map[string]bool{}
+
+	var expand func(key string, stack []string) (string, error)
+	expand = func(key string, stack []string) (string, error) {
+		if value, ok := expanded[key]; ok {
+			return value, nil
+		}
+		if slices.Contains(stack, key) {
+			return "", fmt.Errorf("reference cycle %s", strings.Join(append(stack, key), " -> "))
+		}
+		stack = append(stack, key)
+
+		value, err := parsers.Expand(r.Items[key], func(name string) (string, bool, error) {
+			local := r.templatePrefixes[key] + name
+			if _, ok := r.Items[local]; !ok || local == key {
+				value, ok := lookup(name)
+				return value, ok, nil
+			}
+			if !r.Templates[local] {
+				return r.Items[local], true, nil
+			}
+			value, err := expand(local, stack)
+			return value, true, err
+		})
+		if err != nil {
+			return "", err
+		}
+		expanded[key] = value
+		return value, nil
+	}
+
+	keys := make([]string, 0, len(r.Templates))
+	for key := range r.Templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, err := expand(key, nil); err != nil {
+			r.AppendError(fmt.Errorf("key '%s': %w", key, err))
+			failed[key] = true
+		}
+	}
+	for _, key := range keys {
+		if failed[key] {
+			delete(r.Items, key)
+		} else {
+			r.Items[key] = expanded[key]
+		}
+	}
+	r.Templates = nil
+	r.templatePrefixes = nil
+}
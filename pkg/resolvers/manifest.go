@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -10,7 +11,7 @@ import (
 )
 
 type manifestSecretsConnector interface {
-	GetSecrets(keys []string) (map[string]string, []error)
+	GetSecrets(ctx context.Context, keys []string) (map[string]string, []error)
 }
 
 type ManifestItems struct {
@@ -30,7 +31,7 @@ func NewManifestResolver(connector manifestSecretsConnector) *ManifestResolver {
 	return &ManifestResolver{connector: connector}
 }
 
-func (m *ManifestResolver) resolveManifestItems(manifestItems *ManifestItems, result *Result) {
+func (m *ManifestResolver) resolveManifestItems(ctx context.Context, manifestItems *ManifestItems, result *Result) {
 
 	numItems := len(manifestItems.Items)
 	secretKeys := make([]string, numItems)
@@ -39,7 +40,7 @@ func (m *ManifestResolver) resolveManifestItems(manifestItems *ManifestItems, re
 		secretKeys[i] = item.Name
 	}
 
-	secrets, errors := m.connector.GetSecrets(secretKeys)
+	secrets, errors := m.connector.GetSecrets(ctx, secretKeys)
 	for _, err := range errors {
 		result.AppendError(err)
 	}
@@ -47,15 +48,19 @@ func (m *ManifestResolver) resolveManifestItems(manifestItems *ManifestItems, re
 	for _, item := range manifestItems.Items {
 		if value, ok := secrets[item.Name]; ok {
 			result.AppendItem(item.Env, value)
+			result.SetReference(normalizeKey(item.Env), secretReference(m.connector, result.Source, item.Name))
 		}
 	}
 }
 
-func (s *ManifestResolver) Resolve(source *config.Source) *Result {
+func (s *ManifestResolver) Resolve(ctx context.Context, source *config.Source) *Result {
 	result := &Result{Source: source}
 	filePath := fmt.Sprintf("%s%s", source.URL.Host, source.URL.Path)
 	f, err := os.ReadFile(filePath)
 	if err != nil {
+		if skipMissing(result, err) {
+			return result
+		}
 		result.AppendError(err)
 		return result
 	}
@@ -66,7 +71,7 @@ func (s *ManifestResolver) Resolve(source *config.Source) *Result {
 		return result
 	}
 
-	s.resolveManifestItems(&manifestItems, result)
+	s.resolveManifestItems(ctx, &manifestItems, result)
 
 	return result
 }
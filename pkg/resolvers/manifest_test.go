@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"context"
 	"errors"
 	"net/url"
 	"os"
@@ -136,7 +137,7 @@ func TestManifestResolve(t *testing.T) {
 			}
 
 			source := &config.Source{URL: sourceURL}
-			result := resolver.Resolve(source)
+			result := resolver.Resolve(context.Background(), source)
 
 			if tt.expectError {
 				if len(result.Errors) == 0 {
@@ -233,7 +234,7 @@ func TestManifestResolveFileErrors(t *testing.T) {
 			}
 
 			source := &config.Source{URL: sourceURL}
-			result := resolver.Resolve(source)
+			result := resolver.Resolve(context.Background(), source)
 
 			if tt.expectError {
 				if len(result.Errors) == 0 {
@@ -326,7 +327,7 @@ func TestResolveManifestItems(t *testing.T) {
 			}
 
 			result := &Result{Items: make(map[string]string)}
-			resolver.resolveManifestItems(tt.manifestItems, result)
+			resolver.resolveManifestItems(context.Background(), tt.manifestItems, result)
 
 			if tt.expectError {
 				if len(result.Errors) == 0 {
@@ -388,7 +389,7 @@ func TestManifestIntegrationWithResolveSource(t *testing.T) {
 	}
 
 	source := &config.Source{URL: sourceURL}
-	result := resolver.Resolve(source)
+	result := resolver.Resolve(context.Background(), source)
 
 	if result == nil {
 		t.Fatal("Expected result, got nil")
@@ -440,7 +441,7 @@ func TestManifestWithSpecialCharacters(t *testing.T) {
 	}
 
 	source := &config.Source{URL: sourceURL}
-	result := resolver.Resolve(source)
+	result := resolver.Resolve(context.Background(), source)
 
 	if len(result.Errors) > 0 {
 		t.Errorf("Unexpected error: %v", result.Errors[0])
@@ -495,7 +496,7 @@ func TestManifestKeysAreCorrectlyPassed(t *testing.T) {
 	}
 
 	source := &config.Source{URL: sourceURL}
-	result := resolver.Resolve(source)
+	result := resolver.Resolve(context.Background(), source)
 
 	if len(result.Errors) > 0 {
 		t.Errorf("Unexpected error: %v", result.Errors[0])
@@ -0,0 +1,67 @@
+package resolvers
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/connectors"
+)
+
+// Factory builds the Resolver for a source
+type Factory func(*config.Source) (Resolver, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func init() {
+	Register("sm", func(source *config.Source) (Resolver, error) {
+		return NewSecretsManagerResolver(connectors.NewSecretsManagerConnector(source)), nil
+	})
+	Register("s3", func(source *config.Source) (Resolver, error) {
+		return &S3ManagerResolver{}, nil
+	})
+	Register("ssm", func(source *config.Source) (Resolver, error) {
+		return NewSSMResolver(connectors.NewSSMConnector(source)), nil
+	})
+	Register("manifest", func(source *config.Source) (Resolver, error) {
+		return NewManifestResolver(connectors.NewSecretsManagerConnector(source)), nil
+	})
+	Register("file", func(source *config.Source) (Resolver, error) {
+		// Connectors only create AWS clients for files with references
+		return NewEnvFileResolver(connectors.NewSecretsManagerConnector(source), connectors.NewSSMConnector(source)), nil
+	})
+}
+
+// Register makes a resolver available for sources with the given URL scheme.
+// Programs embedding snagsby can use it to add their own schemes, registering
+// a scheme that already exists replaces its factory.
+func Register(scheme string, factory Factory) {
+	if factory == nil {
+		panic("resolvers: Register factory is nil for scheme " + scheme)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[scheme] = factory
+}
+
+// Schemes returns the sorted list of registered schemes
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+func lookupFactory(scheme string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := registry[scheme]
+	return factory, ok
+}
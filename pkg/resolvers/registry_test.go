@@ -0,0 +1,76 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+)
+
+type staticResolver struct {
+	items map[string]string
+}
+
+func (s *staticResolver) Resolve(ctx context.Context, source *config.Source) *Result {
+	result := &Result{Source: source}
+	result.AppendItems(s.items)
+	return result
+}
+
+func TestBuiltinSchemesRegistered(t *testing.T) {
+	schemes := Schemes()
+	for _, scheme := range []string{"file", "manifest", "s3", "sm", "ssm"} {
+		if !slices.Contains(schemes, scheme) {
+			t.Errorf("Expected built in scheme %s to be registered, got %v", scheme, schemes)
+		}
+	}
+	if !slices.IsSorted(schemes) {
+		t.Errorf("Expected schemes to be sorted, got %v", schemes)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-static", func(source *config.Source) (Resolver, error) {
+		return &staticResolver{items: map[string]string{"host": source.URL.Host}}, nil
+	})
+	Register("test-broken", func(source *config.Source) (Resolver, error) {
+		return nil, errors.New("broken backend")
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "test-static")
+		delete(registry, "test-broken")
+		registryMu.Unlock()
+	}()
+
+	if !slices.Contains(Schemes(), "test-static") {
+		t.Errorf("Expected test-static in %v", Schemes())
+	}
+
+	sourceURL, _ := url.Parse("test-static://example")
+	result := ResolveSource(context.Background(), &config.Source{URL: sourceURL})
+	if result.HasErrors() {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+	if result.Items["HOST"] != "example" {
+		t.Errorf("Expected HOST=example, got %v", result.Items)
+	}
+
+	sourceURL, _ = url.Parse("test-broken://example")
+	result = ResolveSource(context.Background(), &config.Source{URL: sourceURL})
+	if len(result.Errors) != 1 || result.Errors[0].Error() != "broken backend" {
+		t.Errorf("Expected the factory error, got %v", result.Errors)
+	}
+}
+
+func TestRegisterNilFactoryPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Register to panic for a nil factory")
+		}
+	}()
+	Register("test-nil", nil)
+}
@@ -1,27 +1,82 @@
 package resolvers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"regexp"
+	"slices"
 	"strings"
 
+	"github.com/aws/smithy-go"
+
 	"github.com/roverdotcom/snagsby/pkg/config"
-	"github.com/roverdotcom/snagsby/pkg/connectors"
+	"github.com/roverdotcom/snagsby/pkg/parsers"
 )
 
 // KeyRegexp is the regular expression that keys must adhere to
 var KeyRegexp *regexp.Regexp = regexp.MustCompile(`[^\w]`)
 
+// recursiveRegexp matches source paths ending in /* which load everything below a prefix
+var recursiveRegexp = regexp.MustCompile(`.*\/\*$`)
+
 // Resolver defines an interface capable of resolving a Source to Result
 type Resolver interface {
-	Resolve(*config.Source) *Result
+	Resolve(context.Context, *config.Source) *Result
+}
+
+// Reference describes where the value of an item was read from
+type Reference struct {
+	// Name identifies the secret, parameter or object holding the value, e.g.
+	// sm://production/app or s3://my-bucket/config.json
+	Name string
+	// Version is the version of the secret or object loaded when it's known
+	Version string
+	// Stage is the staging label a Secrets Manager secret was requested with
+	Stage string
+	// ETag is the entity tag of an S3 object, which identifies the content
+	// loaded when the bucket isn't versioned
+	ETag string
+}
+
+// String describes the reference along with the version or ETag loaded and
+// the stage requested
+func (r Reference) String() string {
+	var details []string
+	switch {
+	case r.Version != "":
+		details = append(details, "version "+r.Version)
+	case r.ETag != "":
+		details = append(details, "etag "+r.ETag)
+	}
+	if r.Stage != "" {
+		details = append(details, "stage "+r.Stage)
+	}
+	if len(details) == 0 {
+		return r.Name
+	}
+	return fmt.Sprintf("%s (%s)", r.Name, strings.Join(details, ", "))
 }
 
 // Result stores a resolved result
 type Result struct {
-	Source *config.Source
-	Errors []error
-	Items  map[string]string
+	Source     *config.Source
+	Errors     []error
+	Warnings   []string
+	Items      map[string]string
+	References map[string]Reference
+	// Templates are the items whose values hold ${VAR} references, they're
+	// expanded by Interpolate once earlier sources are resolved
+	Templates map[string]bool
+	// templatePrefixes holds the prefix added to the keys of templates that
+	// were namespaced after they were read, their references are unprefixed
+	templatePrefixes map[string]string
+}
+
+// normalizeKey converts a name to the key AppendItem stores it under
+func normalizeKey(key string) string {
+	return strings.ToUpper(KeyRegexp.ReplaceAllString(key, "_"))
 }
 
 // AppendItem adds an item to the internal Items map
@@ -30,8 +85,7 @@ func (r *Result) AppendItem(key, value string) {
 	if r.Items == nil {
 		r.Items = map[string]string{}
 	}
-	key = strings.ToUpper(KeyRegexp.ReplaceAllString(key, "_"))
-	r.Items[key] = value
+	r.Items[normalizeKey(key)] = value
 }
 
 // AppendItemExact adds an item to the internal Items map without any key normalization.
@@ -55,11 +109,54 @@ func (r *Result) AppendItems(items map[string]string) {
 	}
 }
 
+// SetReference records where the item stored under key was read from
+func (r *Result) SetReference(key string, reference Reference) {
+	if r.References == nil {
+		r.References = map[string]Reference{}
+	}
+	r.References[key] = reference
+}
+
+// SetTemplate marks the item stored under key as a value to interpolate
+func (r *Result) SetTemplate(key string) {
+	if r.Templates == nil {
+		r.Templates = map[string]bool{}
+	}
+	r.Templates[key] = true
+}
+
+// setPrefixedTemplate marks the item stored under prefix+key as a value to
+// interpolate whose references to other items of its object don't carry the
+// prefix, ${HOST} in a template stored under DB_URL reads DB_HOST
+func (r *Result) setPrefixedTemplate(prefix, key string) {
+	r.SetTemplate(prefix + key)
+	if prefix == "" {
+		return
+	}
+	if r.templatePrefixes == nil {
+		r.templatePrefixes = map[string]string{}
+	}
+	r.templatePrefixes[prefix+key] = prefix
+}
+
+// setReferenceAll records reference for every item in the result, for sources
+// whose items are all read from a single secret or object
+func (r *Result) setReferenceAll(reference Reference) {
+	for key := range r.Items {
+		r.SetReference(key, reference)
+	}
+}
+
 // AppendError adds an error to the result
 func (r *Result) AppendError(err error) {
 	r.Errors = append(r.Errors, err)
 }
 
+// AppendWarning adds a non fatal warning to the result
+func (r *Result) AppendWarning(warning string) {
+	r.Warnings = append(r.Warnings, warning)
+}
+
 // HasErrors indicates whether or not this result has errors
 func (r *Result) HasErrors() bool {
 	return len(r.Errors) > 0
@@ -79,8 +176,99 @@ func (r *Result) LenItems() int {
 	return len(r.Items)
 }
 
+// keyNameFromPrefix strips prefix from name and normalizes the remainder to a key
+func keyNameFromPrefix(prefix, name string) string {
+	key := strings.TrimPrefix(name, prefix)
+	key = KeyRegexp.ReplaceAllString(key, "_")
+	key = strings.ToUpper(key)
+	return key
+}
+
+// isRecursiveSource indicates whether a source refers to everything below a prefix
+func isRecursiveSource(source *config.Source) bool {
+	sourceURL := source.URL
+	return recursiveRegexp.MatchString(strings.Join([]string{sourceURL.Host, sourceURL.Path}, ""))
+}
+
+// jsonOptions reads the JSON handling options from a source's query string
+func jsonOptions(source *config.Source) (parsers.JSONOptions, error) {
+	query := source.URL.Query()
+	options := parsers.JSONOptions{Separator: query.Get("flatten")}
+
+	switch arrays := query.Get("arrays"); arrays {
+	case "":
+	case "json":
+		options.EncodeArrays = true
+	default:
+		return options, fmt.Errorf("invalid arrays option %q, expected json", arrays)
+	}
+
+	switch nulls := query.Get("nulls"); nulls {
+	case "", "skip":
+	case "error":
+		options.ErrorOnNull = true
+	default:
+		return options, fmt.Errorf("invalid nulls option %q, expected skip or error", nulls)
+	}
+
+	return options, nil
+}
+
+// appendJSONItems reads a JSON object into the result using the source's JSON options
+func appendJSONItems(input string, result *Result) {
+	appendParsedItems(input, result, parsers.ReadJSONStringWithOptions)
+}
+
+// appendYAMLItems reads a YAML mapping into the result using the source's JSON options
+func appendYAMLItems(input string, result *Result) {
+	appendParsedItems(input, result, parsers.ReadYAMLStringWithOptions)
+}
+
+func appendParsedItems(input string, result *Result, read func(string, parsers.JSONOptions) (map[string]string, []string, error)) {
+	options, err := jsonOptions(result.Source)
+	if err != nil {
+		result.AppendError(err)
+		return
+	}
+
+	out, warnings, err := read(input, options)
+	for _, warning := range warnings {
+		result.AppendWarning(warning)
+	}
+	if err != nil {
+		result.AppendError(err)
+		return
+	}
+
+	result.AppendItems(out)
+}
+
+// notFoundCodes are the AWS error codes for a missing object, object version,
+// secret or parameter
+var notFoundCodes = []string{"NoSuchKey", "NoSuchVersion", "ResourceNotFoundException", "ParameterNotFound"}
+
+// isNotFound indicates whether err means the file, object or secret of a
+// source doesn't exist, as opposed to being unreadable
+func isNotFound(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && slices.Contains(notFoundCodes, apiErr.ErrorCode())
+}
+
+// skipMissing reports whether err can be ignored because the source is
+// optional and what it refers to doesn't exist, a warning is recorded instead
+func skipMissing(result *Result, err error) bool {
+	if optional, _ := result.Source.Optional(); !optional || !isNotFound(err) {
+		return false
+	}
+	result.AppendWarning(fmt.Sprintf("skipping optional source: %v", err))
+	return true
+}
+
 // ResolveSource will resolve a config.Source to a Result object
-func ResolveSource(source *config.Source) *Result {
+func ResolveSource(ctx context.Context, source *config.Source) *Result {
 	if source == nil {
 		return &Result{
 			Source: nil,
@@ -94,32 +282,18 @@ func ResolveSource(source *config.Source) *Result {
 			Errors: []error{fmt.Errorf("resolvers.ResolveSource: source.URL must not be nil")},
 		}
 	}
-	sourceURL := source.URL
-	var s Resolver
-	switch sourceURL.Scheme {
-	case "sm":
-		connector, err := connectors.NewSecretsManagerConnector(source)
-		if err != nil {
-			return &Result{Source: source, Errors: []error{err}}
-		}
-		s = NewSecretsManagerResolver(connector)
-	case "s3":
-		s = &S3ManagerResolver{}
-	case "manifest":
-		connector, err := connectors.NewSecretsManagerConnector(source)
-		if err != nil {
-			return &Result{Source: source, Errors: []error{err}}
-		}
-		s = NewManifestResolver(connector)
-	case "file":
-		connector, err := connectors.NewSecretsManagerConnector(source)
-		if err != nil {
-			return &Result{Source: source, Errors: []error{err}}
-		}
-		s = NewEnvFileResolver(connector)
-	default:
-		return &Result{Source: source, Errors: []error{fmt.Errorf("No resolver found for scheme %s", sourceURL.Scheme)}}
+	if _, err := source.Optional(); err != nil {
+		return &Result{Source: source, Errors: []error{err}}
+	}
+	factory, ok := lookupFactory(source.URL.Scheme)
+	if !ok {
+		return &Result{Source: source, Errors: []error{fmt.Errorf("No resolver found for scheme %s", source.URL.Scheme)}}
+	}
+
+	s, err := factory(source)
+	if err != nil {
+		return &Result{Source: source, Errors: []error{err}}
 	}
 
-	return s.Resolve(source)
+	return s.Resolve(ctx, source)
 }
@@ -1,10 +1,17 @@
 package resolvers
 
 import (
+	"context"
+	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/aws/smithy-go"
 	"github.com/roverdotcom/snagsby/pkg/config"
+	connectortesting "github.com/roverdotcom/snagsby/pkg/connectors/testing"
+	"github.com/roverdotcom/snagsby/pkg/parsers"
 )
 
 func TestKeyRegexp(t *testing.T) {
@@ -119,10 +126,13 @@ func TestLenItems(t *testing.T) {
 }
 
 func TestResolveSource(t *testing.T) {
+	// Fail fast instead of waiting on the EC2 metadata service for credentials
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
 	// Test with invalid scheme
 	invalidURL, _ := url.Parse("invalid://test/path")
 	source := &config.Source{URL: invalidURL}
-	result := ResolveSource(source)
+	result := ResolveSource(context.Background(), source)
 
 	if !result.HasErrors() {
 		t.Error("Expected error for invalid scheme")
@@ -132,11 +142,11 @@ func TestResolveSource(t *testing.T) {
 	}
 
 	// Test with each valid scheme (will error due to no AWS/missing file, but scheme routing works)
-	schemes := []string{"s3", "sm", "manifest", "file"}
+	schemes := []string{"s3", "sm", "ssm", "manifest", "file"}
 	for _, scheme := range schemes {
 		testURL, _ := url.Parse(scheme + "://test/path")
 		testSource := &config.Source{URL: testURL}
-		result := ResolveSource(testSource)
+		result := ResolveSource(context.Background(), testSource)
 
 		// Result should exist (even if it has errors due to missing AWS resources or files)
 		if result == nil {
@@ -153,6 +163,40 @@ func TestResolveSource(t *testing.T) {
 	}
 }
 
+func TestJSONOptions(t *testing.T) {
+	tests := []struct {
+		sourceURL   string
+		expected    parsers.JSONOptions
+		expectError bool
+	}{
+		{sourceURL: "s3://bucket/config.json", expected: parsers.JSONOptions{}},
+		{sourceURL: "s3://bucket/config.json?flatten=_", expected: parsers.JSONOptions{Separator: "_"}},
+		{sourceURL: "sm://secret?flatten=__&arrays=json&nulls=error", expected: parsers.JSONOptions{Separator: "__", EncodeArrays: true, ErrorOnNull: true}},
+		{sourceURL: "sm://secret?nulls=skip", expected: parsers.JSONOptions{}},
+		{sourceURL: "sm://secret?arrays=csv", expectError: true},
+		{sourceURL: "sm://secret?nulls=empty", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sourceURL, func(t *testing.T) {
+			parsedURL, _ := url.Parse(tt.sourceURL)
+			options, err := jsonOptions(&config.Source{URL: parsedURL})
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if options != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, options)
+			}
+		})
+	}
+}
+
 // Helper error type for testing
 type testError struct {
 	msg string
@@ -161,3 +205,66 @@ type testError struct {
 func (e *testError) Error() string {
 	return e.msg
 }
+
+func TestOptionalSources(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.snagsby")
+	os.WriteFile(invalid, []byte("bad-key=1\n"), 0o600)
+
+	notFound := &smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "secret not found"}
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not allowed"}
+	secretsResolver := func(err error) Resolver {
+		return NewSecretsManagerResolver(&connectortesting.MockSecretsConnector{
+			GetSecretFunc: func(secretName string) (string, error) {
+				return "", fmt.Errorf("fetching secret %q: %w", secretName, err)
+			},
+		})
+	}
+	fileResolver := NewEnvFileResolver(&connectortesting.MockSecretsConnector{}, &connectortesting.MockParametersConnector{})
+	manifestResolver := NewManifestResolver(&connectortesting.MockSecretsConnector{})
+	parameterResolver := NewSSMResolver(connectortesting.NewSSMConnectorWithFakeParameters(map[string]string{}, nil))
+
+	tests := []struct {
+		name          string
+		rawSource     string
+		resolver      Resolver
+		expectError   bool
+		expectWarning bool
+	}{
+		{name: "missing file", rawSource: "file://" + dir + "/missing.snagsby", resolver: fileResolver, expectError: true},
+		{name: "optional missing file", rawSource: "file://" + dir + "/missing.snagsby?optional=true", resolver: fileResolver, expectWarning: true},
+		{name: "optional missing file with prefix", rawSource: "?file://" + dir + "/missing.snagsby", resolver: fileResolver, expectWarning: true},
+		{name: "optional file with parse errors", rawSource: "?file://" + invalid, resolver: fileResolver, expectError: true},
+		{name: "missing secret", rawSource: "sm://prod/app", resolver: secretsResolver(notFound), expectError: true},
+		{name: "optional missing secret", rawSource: "sm://prod/app?optional=true", resolver: secretsResolver(notFound), expectWarning: true},
+		{name: "optional denied secret", rawSource: "?sm://prod/app", resolver: secretsResolver(denied), expectError: true},
+		{name: "missing manifest", rawSource: "manifest://" + dir + "/missing.yaml", resolver: manifestResolver, expectError: true},
+		{name: "optional missing manifest", rawSource: "?manifest://" + dir + "/missing.yaml", resolver: manifestResolver, expectWarning: true},
+		{name: "optional missing parameter", rawSource: "?ssm:///prod/app/host", resolver: parameterResolver, expectWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snagsbyConfig := config.NewConfig()
+			if err := snagsbyConfig.SetSources([]string{tt.rawSource}, ""); err != nil {
+				t.Fatal(err)
+			}
+			result := tt.resolver.Resolve(context.Background(), snagsbyConfig.Sources[0])
+
+			if result.HasErrors() != tt.expectError {
+				t.Errorf("Expected errors %t, got %v", tt.expectError, result.Errors)
+			}
+			if (len(result.Warnings) > 0) != tt.expectWarning {
+				t.Errorf("Expected a warning %t, got %v", tt.expectWarning, result.Warnings)
+			}
+			if len(result.Items) != 0 {
+				t.Errorf("Expected no items, got %v", result.Items)
+			}
+		})
+	}
+
+	invalidOption, _ := url.Parse("file://local.snagsby?optional=sometimes")
+	if result := ResolveSource(context.Background(), &config.Source{URL: invalidOption}); !result.HasErrors() {
+		t.Error("Expected an error for an invalid optional option")
+	}
+}
@@ -1,19 +1,46 @@
 package resolvers
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"path"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/roverdotcom/snagsby/pkg/clients"
 	"github.com/roverdotcom/snagsby/pkg/config"
-	"github.com/roverdotcom/snagsby/pkg/parsers"
+	"github.com/roverdotcom/snagsby/pkg/connectors"
 )
 
+// objectFormats are the formats an S3 object can be read as
+var objectFormats = []string{"json", "yaml", "dotenv"}
+
+// sha256Regexp matches the hex encoded digest expected by the ?sha256= option
+var sha256Regexp = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// objectOptions are the options of an S3 source that select and read its
+// objects, they don't apply to the references in dotenv objects
+var objectOptions = []string{"version-id", "sha256", "format", "namespace"}
+
+// s3FetchConcurrency limits the objects of a recursive source fetched at once
+const s3FetchConcurrency = 10
+
 // S3ManagerResolver handles s3 resolution
-type S3ManagerResolver struct{}
+type S3ManagerResolver struct {
+	// envFile resolves the references in dotenv objects, one is built for the
+	// source when it's nil
+	envFile *EnvFileResolver
+}
 
 func (s *S3ManagerResolver) sanitizeKey(key string) string {
 	// Strip only the leading slash
@@ -21,43 +48,241 @@ func (s *S3ManagerResolver) sanitizeKey(key string) string {
 	return m.ReplaceAllString(key, "")
 }
 
-// Resolve returns results
-func (s *S3ManagerResolver) Resolve(source *config.Source) *Result {
-	result := &Result{Source: source}
-	sourceURL := source.URL
+// referenceSource returns a copy of source without its objectOptions, the
+// source sm:// and ssm:// references in dotenv objects are fetched for
+func referenceSource(source *config.Source) *config.Source {
+	sourceURL := *source.URL
+	query := sourceURL.Query()
+	for _, option := range objectOptions {
+		query.Del(option)
+	}
+	sourceURL.RawQuery = query.Encode()
+	copied := *source
+	copied.URL = &sourceURL
+	return &copied
+}
 
-	cfg, err := clients.GetAwsConfig()
+// envFileResolver returns the resolver for dotenv objects of a source, its
+// connectors are built for the referenceSource of source
+func (s *S3ManagerResolver) envFileResolver(source *config.Source) *EnvFileResolver {
+	if s.envFile != nil {
+		return s.envFile
+	}
+	return NewEnvFileResolver(connectors.NewSecretsManagerConnector(source), connectors.NewSSMConnector(source))
+}
 
-	if err != nil {
-		result.AppendError(err)
-		return result
+// detectObjectFormat returns the format of an object from the extension of its
+// key, then its Content-Type, and json when neither is recognized
+func detectObjectFormat(key, contentType string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".env", ".snagsby":
+		return "dotenv"
 	}
 
-	region := sourceURL.Query().Get("region")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return "yaml"
+	}
+	return "json"
+}
 
-	if region != "" {
-		cfg.Region = region
+// resolveObject reads a single object into a result of its own, format is
+// detected from the object when it's empty. The ?version-id= option loads a
+// specific version of the object and ?sha256= fails the object when the
+// digest of its body doesn't match.
+func (s *S3ManagerResolver) resolveObject(ctx context.Context, svc *s3.Client, source *config.Source, key, format string) *Result {
+	result := &Result{Source: source}
+	bucket := source.URL.Host
+
+	query := source.URL.Query()
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
 	}
-	svc := s3.NewFromConfig(cfg)
-	res, s3err := svc.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(sourceURL.Host),
-		Key:    aws.String(s.sanitizeKey(sourceURL.Path)),
-	})
+	if versionID := query.Get("version-id"); versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	res, s3err := svc.GetObject(ctx, input)
 
 	if s3err != nil {
+		if skipMissing(result, s3err) {
+			return result
+		}
 		result.AppendError(s3err)
 		return result
 	}
 	defer res.Body.Close()
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(res.Body)
-	bodyStr := buf.String()
-	out, err := parsers.ReadJSONString(bodyStr)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		result.AppendError(fmt.Errorf("reading s3://%s/%s: %w", bucket, key, err))
+		return result
+	}
+	if expected := query.Get("sha256"); expected != "" {
+		digest := sha256.Sum256(body)
+		if actual := hex.EncodeToString(digest[:]); !strings.EqualFold(actual, expected) {
+			result.AppendError(fmt.Errorf("s3://%s/%s has sha256 %s, expected %s", bucket, key, actual, expected))
+			return result
+		}
+	}
+	bodyStr := string(body)
+
+	if format == "" {
+		format = detectObjectFormat(key, aws.ToString(res.ContentType))
+	}
+	switch format {
+	case "yaml":
+		appendYAMLItems(bodyStr, result)
+	case "dotenv":
+		references := referenceSource(source)
+		s.envFileResolver(references).resolveParsed(ctx, references, parseEnvFile(strings.NewReader(bodyStr), result), result)
+	default:
+		appendJSONItems(bodyStr, result)
+	}
+
+	// Items read from sm:// or ssm:// references keep their own reference
+	reference := Reference{
+		Name:    "s3://" + bucket + "/" + key,
+		Version: aws.ToString(res.VersionId),
+		ETag:    strings.Trim(aws.ToString(res.ETag), `"`),
+	}
+	for itemKey := range result.Items {
+		if _, ok := result.References[itemKey]; !ok {
+			result.SetReference(itemKey, reference)
+		}
+	}
+	return result
+}
+
+// listObjects returns the keys of the objects below prefix in lexical order,
+// folder placeholders ending in / are skipped
+func (s *S3ManagerResolver) listObjects(ctx context.Context, svc *s3.Client, bucket, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(svc, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return keys, fmt.Errorf("listing objects under s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, object := range output.Contents {
+			key := aws.ToString(object.Key)
+			if key == "" || strings.HasSuffix(key, "/") {
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// resolveRecursive reads every object below a prefix. Objects are fetched
+// concurrently and merged in lexical key order, so a key in a later object
+// overrides the same key in an earlier one. With ?namespace=true keys are
+// prefixed with the object's name, db.json's HOST becomes DB_HOST.
+func (s *S3ManagerResolver) resolveRecursive(ctx context.Context, svc *s3.Client, source *config.Source, format string, namespace bool) *Result {
+	result := &Result{Source: source}
+	bucket := source.URL.Host
+	prefix := s.sanitizeKey(strings.TrimSuffix(source.URL.Path, "*"))
+
+	keys, err := s.listObjects(ctx, svc, bucket, prefix)
 	if err != nil {
 		result.AppendError(err)
 		return result
 	}
-	result.AppendItems(out)
+
+	objects := make([]*Result, len(keys))
+	jobs := make(chan int, len(keys))
+	for i := range keys {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(len(keys), s3FetchConcurrency); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				objects[i] = s.resolveObject(ctx, svc, source, keys[i], format)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		object := objects[i]
+		name := "s3://" + bucket + "/" + key
+		for _, err := range object.Errors {
+			result.AppendError(fmt.Errorf("%s: %w", name, err))
+		}
+		for _, warning := range object.Warnings {
+			result.AppendWarning(name + ": " + warning)
+		}
+
+		itemPrefix := ""
+		if namespace {
+			itemPrefix = keyNameFromPrefix(prefix, strings.TrimSuffix(key, path.Ext(key))) + "_"
+		}
+		for itemKey, value := range object.Items {
+			result.AppendItemExact(itemPrefix+itemKey, value)
+			result.SetReference(itemPrefix+itemKey, object.References[itemKey])
+			if object.Templates[itemKey] {
+				result.setPrefixedTemplate(itemPrefix, itemKey)
+			}
+		}
+	}
+
 	return result
 }
+
+// Resolve returns results
+func (s *S3ManagerResolver) Resolve(ctx context.Context, source *config.Source) *Result {
+	result := &Result{Source: source}
+	sourceURL := source.URL
+	query := sourceURL.Query()
+
+	format := query.Get("format")
+	if format != "" && !slices.Contains(objectFormats, format) {
+		result.AppendError(fmt.Errorf("invalid format %q, expected json, yaml or dotenv", format))
+		return result
+	}
+
+	namespace := false
+	if query.Has("namespace") {
+		var err error
+		if namespace, err = strconv.ParseBool(query.Get("namespace")); err != nil {
+			result.AppendError(fmt.Errorf("invalid namespace option %q, expected true or false", query.Get("namespace")))
+			return result
+		}
+	}
+
+	if sha := query.Get("sha256"); sha != "" && !sha256Regexp.MatchString(sha) {
+		result.AppendError(fmt.Errorf("invalid sha256 option %q, expected a hex encoded SHA-256 digest", sha))
+		return result
+	}
+	recursive := isRecursiveSource(source)
+	if recursive && (query.Has("version-id") || query.Has("sha256")) {
+		result.AppendError(fmt.Errorf("the version-id and sha256 options can only be used with a single object"))
+		return result
+	}
+
+	svc, err := clients.NewS3Client(ctx, sourceURL)
+	if err != nil {
+		result.AppendError(err)
+		return result
+	}
+
+	if recursive {
+		return s.resolveRecursive(ctx, svc, source, format, namespace)
+	}
+	return s.resolveObject(ctx, svc, source, s.sanitizeKey(sourceURL.Path), format)
+}
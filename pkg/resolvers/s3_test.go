@@ -1,6 +1,22 @@
 package resolvers
 
-import "testing"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+	connectortesting "github.com/roverdotcom/snagsby/pkg/connectors/testing"
+)
 
 func TestSanitizeKey(t *testing.T) {
 	s := S3ManagerResolver{}
@@ -19,3 +35,485 @@ func TestSanitizeKey(t *testing.T) {
 		}
 	}
 }
+
+func TestS3ResolveWithEndpoint(t *testing.T) {
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"api_key": "abc123", "processes": 2}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	sourceURL, _ := url.Parse("s3://my-bucket/config.json?endpoint=" + url.QueryEscape(server.URL))
+	result := (&S3ManagerResolver{}).Resolve(context.Background(), &config.Source{URL: sourceURL})
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	// Path style addressing puts the bucket in the path
+	if requestedPath != "/my-bucket/config.json" {
+		t.Errorf("Expected a path style request for /my-bucket/config.json, got %s", requestedPath)
+	}
+	expected := map[string]string{"API_KEY": "abc123", "PROCESSES": "2"}
+	if !reflect.DeepEqual(result.Items, expected) {
+		t.Errorf("Expected %v, got %v", expected, result.Items)
+	}
+}
+
+func TestDetectObjectFormat(t *testing.T) {
+	tests := []struct {
+		key         string
+		contentType string
+		expected    string
+	}{
+		{"config.json", "", "json"},
+		{"config.yaml", "", "yaml"},
+		{"config.YML", "binary/octet-stream", "yaml"},
+		{"config.env", "", "dotenv"},
+		{".env", "", "dotenv"},
+		{"production.snagsby", "", "dotenv"},
+		{"config", "application/x-yaml; charset=utf-8", "yaml"},
+		{"config", "text/yaml", "yaml"},
+		{"config.json", "text/yaml", "json"},
+		{"config", "application/json", "json"},
+		{"config", "", "json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+" "+tt.contentType, func(t *testing.T) {
+			if actual := detectObjectFormat(tt.key, tt.contentType); actual != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestS3ResolveFormats(t *testing.T) {
+	objects := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"/my-bucket/config.json": {"application/json", `{"api_key": "abc123", "processes": 2}`},
+		"/my-bucket/config.yaml": {"binary/octet-stream", "api_key: abc123\nprocesses: 2\n"},
+		"/my-bucket/config":      {"application/yaml", "api_key: abc123\nprocesses: 2\n"},
+		"/my-bucket/app.env":     {"text/plain", "#include base.env\nAPI_KEY=abc123\n# comment\nDB_PASSWORD=sm://prod/db\n"},
+		"/my-bucket/settings":    {"text/plain", "API_KEY='abc123'\nPROCESSES=2\n"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		object, ok := objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Write([]byte(object.body))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	tests := []struct {
+		name        string
+		rawURL      string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name:     "json extension",
+			rawURL:   "s3://my-bucket/config.json",
+			expected: map[string]string{"API_KEY": "abc123", "PROCESSES": "2"},
+		},
+		{
+			name:     "yaml extension",
+			rawURL:   "s3://my-bucket/config.yaml",
+			expected: map[string]string{"API_KEY": "abc123", "PROCESSES": "2"},
+		},
+		{
+			name:     "yaml content type",
+			rawURL:   "s3://my-bucket/config",
+			expected: map[string]string{"API_KEY": "abc123", "PROCESSES": "2"},
+		},
+		{
+			name:     "dotenv with references",
+			rawURL:   "s3://my-bucket/app.env",
+			expected: map[string]string{"API_KEY": "abc123", "DB_PASSWORD": "hunter2"},
+		},
+		{
+			name:     "format option",
+			rawURL:   "s3://my-bucket/settings?format=dotenv",
+			expected: map[string]string{"API_KEY": "abc123", "PROCESSES": "2"},
+		},
+		{
+			name:        "format option overrides detection",
+			rawURL:      "s3://my-bucket/config.yaml?format=json",
+			expectError: true,
+		},
+		{
+			name:        "invalid format option",
+			rawURL:      "s3://my-bucket/config.json?format=toml",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceURL, _ := url.Parse(tt.rawURL)
+			query := sourceURL.Query()
+			query.Set("endpoint", server.URL)
+			sourceURL.RawQuery = query.Encode()
+			source := &config.Source{URL: sourceURL}
+
+			resolver := &S3ManagerResolver{envFile: NewEnvFileResolver(
+				connectortesting.NewSecretsManagerConnectorWithFakeSecrets(map[string]string{"prod/db": "hunter2"}, source),
+				&connectortesting.MockParametersConnector{},
+			)}
+			result := resolver.Resolve(context.Background(), source)
+
+			if tt.expectError {
+				if !result.HasErrors() {
+					t.Errorf("Expected an error, got %v", result.Items)
+				}
+				return
+			}
+			if result.HasErrors() {
+				t.Fatalf("Unexpected errors: %v", result.Errors)
+			}
+			if !reflect.DeepEqual(result.Items, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result.Items)
+			}
+			if reference := result.References["API_KEY"]; !strings.HasPrefix(reference.Name, "s3://my-bucket/") {
+				t.Errorf("Expected an s3 reference for API_KEY, got %v", reference)
+			}
+			if reference, ok := result.References["DB_PASSWORD"]; ok && reference.Name != "sm://prod/db" {
+				t.Errorf("Expected the sm://prod/db reference for DB_PASSWORD, got %v", reference)
+			}
+		})
+	}
+}
+
+// TestS3ResolvePinnedDotenvReferences tests that the options selecting an
+// object aren't applied to the secrets its references point to
+func TestS3ResolvePinnedDotenvReferences(t *testing.T) {
+	var secretRequests []string
+	var objectVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Secrets Manager and S3 requests share the endpoint
+		if target := r.Header.Get("X-Amz-Target"); target != "" {
+			body, _ := io.ReadAll(r.Body)
+			secretRequests = append(secretRequests, target+" "+string(body))
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			w.Write([]byte(`{"SecretValues": [{"Name": "prod/db", "SecretString": "hunter2", "VersionId": "a1b2c3"}], "Errors": []}`))
+			return
+		}
+		objectVersion = r.URL.Query().Get("versionId")
+		w.Header().Set("x-amz-version-id", objectVersion)
+		w.Write([]byte("API_KEY=abc123\nDB_PASSWORD=sm://prod/db\n"))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	sourceURL, _ := url.Parse("s3://my-bucket/app.env?version-id=3HL4kqtJlcpXroDTDmJ&endpoint=" + url.QueryEscape(server.URL))
+	result := (&S3ManagerResolver{}).Resolve(context.Background(), &config.Source{URL: sourceURL})
+
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if objectVersion != "3HL4kqtJlcpXroDTDmJ" {
+		t.Errorf("Expected the pinned object version to be requested, got %q", objectVersion)
+	}
+	expected := map[string]string{"API_KEY": "abc123", "DB_PASSWORD": "hunter2"}
+	if !reflect.DeepEqual(result.Items, expected) {
+		t.Errorf("Expected %v, got %v", expected, result.Items)
+	}
+	if len(secretRequests) != 1 || !strings.HasPrefix(secretRequests[0], "secretsmanager.BatchGetSecretValue ") || strings.Contains(secretRequests[0], "3HL4kqtJlcpXroDTDmJ") {
+		t.Errorf("Expected a single batch request without the object version, got %v", secretRequests)
+	}
+	expectedReference := Reference{Name: "sm://prod/db", Version: "a1b2c3", Stage: "AWSCURRENT"}
+	if reference := result.References["DB_PASSWORD"]; reference != expectedReference {
+		t.Errorf("Expected reference %v, got %v", expectedReference, reference)
+	}
+}
+
+func TestS3ResolveRecursive(t *testing.T) {
+	objects := map[string]string{
+		"config/prod/":          "",
+		"config/prod/app.json":  `{"host": "app.example.com", "debug": false}`,
+		"config/prod/db.yaml":   "host: db.example.com\nport: 5432\n",
+		"config/prod/eu/db.env": "HOST=eu-db.example.com\nURL=\"postgres://${HOST}:${PORT:-5432}/app\"\n",
+		"config/staging.json":   `{"host": "staging.example.com"}`,
+	}
+	var listRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+		if r.URL.Query().Get("list-type") != "2" {
+			w.Write([]byte(objects[key]))
+			return
+		}
+
+		// List a single object per page to exercise pagination
+		listRequests++
+		prefix := r.URL.Query().Get("prefix")
+		var keys []string
+		for key := range objects {
+			if strings.HasPrefix(key, prefix) && key > r.URL.Query().Get("continuation-token") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>my-bucket</Name><Prefix>%s</Prefix>`, prefix)
+		if len(keys) > 0 {
+			fmt.Fprintf(w, "<KeyCount>1</KeyCount><Contents><Key>%s</Key></Contents>", keys[0])
+		}
+		if len(keys) > 1 {
+			fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", keys[0])
+		} else {
+			w.Write([]byte("<IsTruncated>false</IsTruncated>"))
+		}
+		w.Write([]byte("</ListBucketResult>"))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	tests := []struct {
+		name             string
+		rawURL           string
+		expected         map[string]string
+		expectedListings int
+		expectError      bool
+	}{
+		{
+			name:             "later objects override earlier ones",
+			rawURL:           "s3://my-bucket/config/prod/*",
+			expected:         map[string]string{"HOST": "eu-db.example.com", "DEBUG": "0", "PORT": "5432", "URL": "postgres://eu-db.example.com:5432/app"},
+			expectedListings: 4,
+		},
+		{
+			name:   "namespaced by object name",
+			rawURL: "s3://my-bucket/config/prod/*?namespace=true",
+			expected: map[string]string{
+				"APP_HOST":   "app.example.com",
+				"APP_DEBUG":  "0",
+				"DB_HOST":    "db.example.com",
+				"DB_PORT":    "5432",
+				"EU_DB_HOST": "eu-db.example.com",
+				"EU_DB_URL":  "postgres://eu-db.example.com:5432/app",
+			},
+			expectedListings: 4,
+		},
+		{
+			name:             "empty prefix",
+			rawURL:           "s3://my-bucket/missing/*",
+			expected:         nil,
+			expectedListings: 1,
+		},
+		{
+			name:        "invalid namespace option",
+			rawURL:      "s3://my-bucket/config/prod/*?namespace=yes",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listRequests = 0
+			sourceURL, _ := url.Parse(tt.rawURL)
+			query := sourceURL.Query()
+			query.Set("endpoint", server.URL)
+			sourceURL.RawQuery = query.Encode()
+
+			result := (&S3ManagerResolver{}).Resolve(context.Background(), &config.Source{URL: sourceURL})
+			result.Interpolate(func(name string) (string, bool) { return "", false })
+
+			if tt.expectError {
+				if !result.HasErrors() {
+					t.Errorf("Expected an error, got %v", result.Items)
+				}
+				return
+			}
+			if result.HasErrors() {
+				t.Fatalf("Unexpected errors: %v", result.Errors)
+			}
+			if !reflect.DeepEqual(result.Items, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result.Items)
+			}
+			if listRequests != tt.expectedListings {
+				t.Errorf("Expected %d list requests, got %d", tt.expectedListings, listRequests)
+			}
+			for key := range result.Items {
+				if reference := result.References[key]; !strings.HasPrefix(reference.Name, "s3://my-bucket/config/prod/") {
+					t.Errorf("Expected an object reference for %s, got %v", key, reference)
+				}
+			}
+		})
+	}
+}
+
+func TestS3ResolveVersionAndDigest(t *testing.T) {
+	body := `{"api_key": "abc123"}`
+	digest := sha256.Sum256([]byte(body))
+	bodySHA := hex.EncodeToString(digest[:])
+
+	var requestedVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedVersion = r.URL.Query().Get("versionId")
+		if requestedVersion == "missing" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchVersion</Code><Message>The specified version does not exist.</Message></Error>"))
+			return
+		}
+		w.Header().Set("ETag", `"9b2cf535f27731c974343645a3985328"`)
+		if requestedVersion != "" {
+			w.Header().Set("x-amz-version-id", requestedVersion)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	tests := []struct {
+		name              string
+		rawURL            string
+		expectedVersion   string
+		expectedReference Reference
+		expectError       bool
+		expectWarning     bool
+	}{
+		{
+			name:              "latest object",
+			rawURL:            "s3://my-bucket/config.json",
+			expectedReference: Reference{Name: "s3://my-bucket/config.json", ETag: "9b2cf535f27731c974343645a3985328"},
+		},
+		{
+			name:              "pinned version",
+			rawURL:            "s3://my-bucket/config.json?version-id=3HL4kqtJlcpXroDTDmJ",
+			expectedVersion:   "3HL4kqtJlcpXroDTDmJ",
+			expectedReference: Reference{Name: "s3://my-bucket/config.json", Version: "3HL4kqtJlcpXroDTDmJ", ETag: "9b2cf535f27731c974343645a3985328"},
+		},
+		{
+			name:              "matching digest",
+			rawURL:            "s3://my-bucket/config.json?sha256=" + strings.ToUpper(bodySHA),
+			expectedReference: Reference{Name: "s3://my-bucket/config.json", ETag: "9b2cf535f27731c974343645a3985328"},
+		},
+		{
+			name:        "mismatched digest",
+			rawURL:      "s3://my-bucket/config.json?sha256=" + strings.Repeat("0", 64),
+			expectError: true,
+		},
+		{
+			name:        "invalid digest",
+			rawURL:      "s3://my-bucket/config.json?sha256=abc",
+			expectError: true,
+		},
+		{
+			name:        "missing version",
+			rawURL:      "s3://my-bucket/config.json?version-id=missing",
+			expectError: true,
+		},
+		{
+			name:          "optional missing version",
+			rawURL:        "s3://my-bucket/config.json?version-id=missing&optional=true",
+			expectWarning: true,
+		},
+		{
+			name:        "recursive source with a version",
+			rawURL:      "s3://my-bucket/config/*?version-id=3HL4kqtJlcpXroDTDmJ",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestedVersion = ""
+			sourceURL, _ := url.Parse(tt.rawURL)
+			query := sourceURL.Query()
+			query.Set("endpoint", server.URL)
+			sourceURL.RawQuery = query.Encode()
+
+			result := (&S3ManagerResolver{}).Resolve(context.Background(), &config.Source{URL: sourceURL})
+
+			if tt.expectError {
+				if !result.HasErrors() || len(result.Items) != 0 {
+					t.Errorf("Expected an error and no items, got %v %v", result.Errors, result.Items)
+				}
+				return
+			}
+			if result.HasErrors() {
+				t.Fatalf("Unexpected errors: %v", result.Errors)
+			}
+			if tt.expectWarning {
+				if len(result.Warnings) != 1 || len(result.Items) != 0 {
+					t.Errorf("Expected a warning and no items, got %v %v", result.Warnings, result.Items)
+				}
+				return
+			}
+			if requestedVersion != tt.expectedVersion {
+				t.Errorf("Expected version %q to be requested, got %q", tt.expectedVersion, requestedVersion)
+			}
+			if reference := result.References["API_KEY"]; reference != tt.expectedReference {
+				t.Errorf("Expected reference %v, got %v", tt.expectedReference, reference)
+			}
+		})
+	}
+}
+
+func TestS3ResolveOptional(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		if strings.HasSuffix(r.URL.Path, "/denied.json") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	tests := []struct {
+		rawURL      string
+		expectError bool
+	}{
+		{rawURL: "s3://my-bucket/missing.json", expectError: true},
+		{rawURL: "s3://my-bucket/missing.json?optional=true", expectError: false},
+		{rawURL: "s3://my-bucket/denied.json?optional=true", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rawURL, func(t *testing.T) {
+			sourceURL, _ := url.Parse(tt.rawURL)
+			query := sourceURL.Query()
+			query.Set("endpoint", server.URL)
+			sourceURL.RawQuery = query.Encode()
+
+			result := (&S3ManagerResolver{}).Resolve(context.Background(), &config.Source{URL: sourceURL})
+			if result.HasErrors() != tt.expectError {
+				t.Errorf("Expected errors %t, got %v", tt.expectError, result.Errors)
+			}
+			if !tt.expectError && len(result.Warnings) != 1 {
+				t.Errorf("Expected a warning for the skipped object, got %v", result.Warnings)
+			}
+		})
+	}
+}
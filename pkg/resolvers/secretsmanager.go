@@ -1,18 +1,17 @@
 package resolvers
 
 import (
+	"context"
 	"fmt"
-	"regexp"
 	"strings"
 
 	"github.com/roverdotcom/snagsby/pkg/config"
-	"github.com/roverdotcom/snagsby/pkg/parsers"
 )
 
 type secretsManagerConnector interface {
-	ListSecrets(prefix string) ([]string, error)
-	GetSecret(secretName string) (string, error)
-	GetSecrets(keys []string) (map[string]string, []error)
+	ListSecrets(ctx context.Context, prefix string) ([]string, error)
+	GetSecret(ctx context.Context, secretName string) (string, error)
+	GetSecrets(ctx context.Context, keys []string) (map[string]string, []error)
 }
 
 type SecretsManagerResolver struct {
@@ -24,67 +23,97 @@ func NewSecretsManagerResolver(connector secretsManagerConnector) *SecretsManage
 }
 
 func (s *SecretsManagerResolver) keyNameFromPrefix(prefix, name string) string {
-	key := strings.TrimPrefix(name, prefix)
-	key = KeyRegexp.ReplaceAllString(key, "_")
-	key = strings.ToUpper(key)
-	return key
+	return keyNameFromPrefix(prefix, name)
 }
 
-func (s *SecretsManagerResolver) resolveRecursive(source *config.Source) *Result {
+func (s *SecretsManagerResolver) resolveRecursive(ctx context.Context, source *config.Source) *Result {
 	result := &Result{Source: source}
 	sourceURL := source.URL
 	prefix := strings.TrimSuffix(fmt.Sprintf("%s%s", sourceURL.Host, sourceURL.Path), "*")
 
-	secretKeys, err := s.connector.ListSecrets(prefix)
+	secretKeys, err := s.connector.ListSecrets(ctx, prefix)
 	if err != nil {
 		result.AppendError(err)
 		return result
 	}
-	secrets, errors := s.connector.GetSecrets(secretKeys)
+	secrets, errors := s.connector.GetSecrets(ctx, secretKeys)
 	for _, err := range errors {
 		result.AppendError(err)
 	}
 
 	for key, value := range secrets {
-		result.AppendItem(s.keyNameFromPrefix(prefix, key), value)
+		itemKey := s.keyNameFromPrefix(prefix, key)
+		result.AppendItem(itemKey, value)
+		result.SetReference(itemKey, secretReference(s.connector, source, key))
 	}
 
 	return result
 }
 
-func (s *SecretsManagerResolver) resolveSingle(source *config.Source) *Result {
+func (s *SecretsManagerResolver) resolveSingle(ctx context.Context, source *config.Source) *Result {
 	result := &Result{Source: source}
 	sourceURL := source.URL
 
 	secretName := strings.Join([]string{sourceURL.Host, sourceURL.Path}, "")
-	secretString, err := s.connector.GetSecret(secretName)
+	secretString, err := s.connector.GetSecret(ctx, secretName)
 	if err != nil {
+		if skipMissing(result, err) {
+			return result
+		}
 		result.AppendError(err)
 		return result
 	}
 
-	out, err := parsers.ReadJSONString(secretString)
-	if err != nil {
-		result.AppendError(err)
-		return result
+	// Binary secrets hold a single value rather than a JSON object, they're
+	// stored under a key named after the last segment of the secret name
+	if source.URL.Query().Has("binary") {
+		prefix := secretName[:strings.LastIndex(secretName, "/")+1]
+		result.AppendItem(keyNameFromPrefix(prefix, secretName), secretString)
+	} else {
+		appendJSONItems(secretString, result)
 	}
-
-	result.AppendItems(out)
+	result.setReferenceAll(secretReference(s.connector, source, secretName))
 
 	return result
 }
 
+// secretVersioner is implemented by connectors that report the version ID of
+// the secret values they fetched
+type secretVersioner interface {
+	SecretVersion(secretName string) string
+}
+
+// secretReference returns the reference of a secret fetched through
+// connector. The version is the version ID that was loaded, when the
+// connector reports it, and the stage is the one the source requests with
+// ?version-stage=, secrets requested without a version default to AWSCURRENT.
+func secretReference(connector any, source *config.Source, secretName string) Reference {
+	reference := Reference{Name: "sm://" + secretName}
+	if source != nil {
+		query := source.URL.Query()
+		reference.Version = query.Get("version-id")
+		reference.Stage = query.Get("version-stage")
+	}
+	if reference.Version == "" && reference.Stage == "" {
+		reference.Stage = "AWSCURRENT"
+	}
+	if versioner, ok := connector.(secretVersioner); ok {
+		if versionID := versioner.SecretVersion(secretName); versionID != "" {
+			reference.Version = versionID
+		}
+	}
+	return reference
+}
+
 func (s *SecretsManagerResolver) isRecursive(source *config.Source) bool {
-	re := regexp.MustCompile(`.*\/\*$`)
-	sourceURL := source.URL
-	return re.MatchString(strings.Join([]string{sourceURL.Host, sourceURL.Path}, ""))
+	return isRecursiveSource(source)
 }
 
 // Resolve returns results
-func (s *SecretsManagerResolver) Resolve(source *config.Source) *Result {
+func (s *SecretsManagerResolver) Resolve(ctx context.Context, source *config.Source) *Result {
 	// Recursive will behave differently
 	if s.isRecursive(source) {
-		return s.resolveRecursive(source)
+		return s.resolveRecursive(ctx, source)
 	}
-	return s.resolveSingle(source)
+	return s.resolveSingle(ctx, source)
 }
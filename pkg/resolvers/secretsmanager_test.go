@@ -1,8 +1,11 @@
 package resolvers
 
 import (
+	"context"
 	"errors"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/roverdotcom/snagsby/pkg/config"
@@ -111,7 +114,7 @@ func TestResolveSingle(t *testing.T) {
 			}
 
 			source := &config.Source{URL: parsedURL}
-			result := resolver.resolveSingle(source)
+			result := resolver.resolveSingle(context.Background(), source)
 
 			if tt.expectError {
 				if len(result.Errors) == 0 {
@@ -139,6 +142,65 @@ func TestResolveSingle(t *testing.T) {
 	}
 }
 
+func TestResolveSingleFlatten(t *testing.T) {
+	mockConnector := &connectortesting.MockSecretsConnector{
+		GetSecretFunc: func(secretName string) (string, error) {
+			return `{"name":"app","db":{"host":"x"},"tags":["a","b"],"empty":null}`, nil
+		},
+		SecretVersions: map[string]string{"my-secret": "a1b2c3"},
+	}
+	resolver := &SecretsManagerResolver{connector: mockConnector}
+
+	parsedURL, _ := url.Parse("sm://my-secret?flatten=_&arrays=json")
+	result := resolver.resolveSingle(context.Background(), &config.Source{URL: parsedURL})
+
+	if len(result.Errors) > 0 {
+		t.Errorf("Unexpected error: %v", result.Errors[0])
+	}
+	expectedItems := map[string]string{
+		"NAME":    "app",
+		"DB_HOST": "x",
+		"TAGS":    `["a","b"]`,
+	}
+	if !reflect.DeepEqual(result.Items, expectedItems) {
+		t.Errorf("Expected %v, got %v", expectedItems, result.Items)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "EMPTY") {
+		t.Errorf("Expected a warning for the dropped EMPTY key, got %v", result.Warnings)
+	}
+	for key := range expectedItems {
+		if reference := result.References[key]; reference != (Reference{Name: "sm://my-secret", Version: "a1b2c3", Stage: "AWSCURRENT"}) {
+			t.Errorf("Unexpected reference for %s: %v", key, reference)
+		}
+	}
+
+	parsedURL, _ = url.Parse("sm://my-secret?flatten=_&nulls=error")
+	result = resolver.resolveSingle(context.Background(), &config.Source{URL: parsedURL})
+	if !result.HasErrors() {
+		t.Error("Expected an error for the null value")
+	}
+}
+
+func TestResolveSingleBinary(t *testing.T) {
+	mockConnector := &connectortesting.MockSecretsConnector{
+		GetSecretFunc: func(secretName string) (string, error) {
+			return "AP9rcw==", nil
+		},
+	}
+	resolver := &SecretsManagerResolver{connector: mockConnector}
+
+	parsedURL, _ := url.Parse("sm://prod/java-keystore?binary=base64")
+	result := resolver.resolveSingle(context.Background(), &config.Source{URL: parsedURL})
+
+	if len(result.Errors) > 0 {
+		t.Errorf("Unexpected error: %v", result.Errors[0])
+	}
+	expectedItems := map[string]string{"JAVA_KEYSTORE": "AP9rcw=="}
+	if !reflect.DeepEqual(result.Items, expectedItems) {
+		t.Errorf("Expected %v, got %v", expectedItems, result.Items)
+	}
+}
+
 func TestResolveRecursive(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -220,7 +282,7 @@ func TestResolveRecursive(t *testing.T) {
 			}
 
 			source := &config.Source{URL: parsedURL}
-			result := resolver.resolveRecursive(source)
+			result := resolver.resolveRecursive(context.Background(), source)
 
 			if tt.expectError {
 				if len(result.Errors) == 0 {
@@ -290,7 +352,7 @@ func TestResolve(t *testing.T) {
 			}
 
 			source := &config.Source{URL: parsedURL}
-			result := resolver.Resolve(source)
+			result := resolver.Resolve(context.Background(), source)
 
 			if result == nil {
 				t.Error("Expected result, got nil")
@@ -0,0 +1,93 @@
+package resolvers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+)
+
+type ssmConnector interface {
+	GetParameter(ctx context.Context, name string) (string, error)
+	GetParametersByPath(ctx context.Context, path string) (map[string]string, error)
+}
+
+// SSMResolver handles AWS Systems Manager Parameter Store resolution
+type SSMResolver struct {
+	connector ssmConnector
+}
+
+func NewSSMResolver(connector ssmConnector) *SSMResolver {
+	return &SSMResolver{connector: connector}
+}
+
+func (s *SSMResolver) parameterName(source *config.Source) string {
+	sourceURL := source.URL
+	return strings.Join([]string{sourceURL.Host, sourceURL.Path}, "")
+}
+
+func (s *SSMResolver) resolveRecursive(ctx context.Context, source *config.Source) *Result {
+	result := &Result{Source: source}
+	prefix := strings.TrimSuffix(s.parameterName(source), "*")
+
+	parameters, err := s.connector.GetParametersByPath(ctx, prefix)
+	if err != nil {
+		result.AppendError(err)
+		return result
+	}
+
+	for name, value := range parameters {
+		key := keyNameFromPrefix(prefix, name)
+		result.AppendItem(key, value)
+		result.SetReference(key, parameterReference(s.connector, name))
+	}
+
+	return result
+}
+
+// resolveSingle stores a single parameter under a key named after the last
+// segment of its path, /prod/db/host becomes HOST
+func (s *SSMResolver) resolveSingle(ctx context.Context, source *config.Source) *Result {
+	result := &Result{Source: source}
+	name := s.parameterName(source)
+
+	value, err := s.connector.GetParameter(ctx, name)
+	if err != nil {
+		if skipMissing(result, err) {
+			return result
+		}
+		result.AppendError(err)
+		return result
+	}
+
+	prefix := name[:strings.LastIndex(name, "/")+1]
+	key := keyNameFromPrefix(prefix, name)
+	result.AppendItem(key, value)
+	result.SetReference(key, parameterReference(s.connector, name))
+
+	return result
+}
+
+// parameterVersioner is implemented by connectors that report the version of
+// the parameter values they fetched
+type parameterVersioner interface {
+	ParameterVersion(name string) string
+}
+
+// parameterReference returns the reference of a parameter fetched through
+// connector, with the version that was loaded when the connector reports it
+func parameterReference(connector any, name string) Reference {
+	reference := Reference{Name: "ssm://" + name}
+	if versioner, ok := connector.(parameterVersioner); ok {
+		reference.Version = versioner.ParameterVersion(name)
+	}
+	return reference
+}
+
+// Resolve returns results
+func (s *SSMResolver) Resolve(ctx context.Context, source *config.Source) *Result {
+	if isRecursiveSource(source) {
+		return s.resolveRecursive(ctx, source)
+	}
+	return s.resolveSingle(ctx, source)
+}
@@ -0,0 +1,90 @@
+package resolvers
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/roverdotcom/snagsby/pkg/config"
+	connectortesting "github.com/roverdotcom/snagsby/pkg/connectors/testing"
+)
+
+func TestSSMResolve(t *testing.T) {
+	parameters := map[string]string{
+		"/prod/db/host":           "db.internal",
+		"/prod/db/port":           "5432",
+		"/prod/db/conn/user-name": "admin",
+		"/prod/other/key":         "unrelated",
+	}
+
+	tests := []struct {
+		name           string
+		sourceURL      string
+		expectedItems  map[string]string
+		expectedErrMsg string
+	}{
+		{
+			name:      "single parameter uses last path segment as key",
+			sourceURL: "ssm:///prod/db/host",
+			expectedItems: map[string]string{
+				"HOST": "db.internal",
+			},
+		},
+		{
+			name:      "recursive parameters are normalized relative to prefix",
+			sourceURL: "ssm:///prod/db/*",
+			expectedItems: map[string]string{
+				"HOST":           "db.internal",
+				"PORT":           "5432",
+				"CONN_USER_NAME": "admin",
+			},
+		},
+		{
+			name:           "missing parameter",
+			sourceURL:      "ssm:///prod/db/missing",
+			expectedItems:  map[string]string{},
+			expectedErrMsg: "ParameterNotFound",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsedURL, err := url.Parse(tt.sourceURL)
+			if err != nil {
+				t.Fatalf("Failed to parse URL: %v", err)
+			}
+			source := &config.Source{URL: parsedURL}
+
+			connector := connectortesting.NewSSMConnectorWithFakeParameters(parameters, source)
+			result := NewSSMResolver(connector).Resolve(context.Background(), source)
+
+			if tt.expectedErrMsg != "" {
+				if len(result.Errors) != 1 {
+					t.Fatalf("Expected 1 error, got %d", len(result.Errors))
+				}
+				if !strings.Contains(result.Errors[0].Error(), tt.expectedErrMsg) {
+					t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErrMsg, result.Errors[0])
+				}
+			} else if len(result.Errors) > 0 {
+				t.Errorf("Unexpected error: %v", result.Errors[0])
+			}
+
+			if len(result.Items) != len(tt.expectedItems) {
+				t.Errorf("Expected %d items, got %d: %v", len(tt.expectedItems), len(result.Items), result.Items)
+			}
+			for key, expectedValue := range tt.expectedItems {
+				if value, ok := result.Items[key]; !ok {
+					t.Errorf("Expected key '%s' not found in result", key)
+				} else if value != expectedValue {
+					t.Errorf("For key '%s', expected value '%s', got '%s'", key, expectedValue, value)
+				}
+			}
+			for key := range tt.expectedItems {
+				if reference := result.References[key]; reference.Version != "1" {
+					t.Errorf("Expected key '%s' to reference version 1, got %v", key, reference)
+				}
+			}
+		})
+	}
+}
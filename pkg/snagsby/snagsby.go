@@ -0,0 +1,173 @@
+// Package snagsby loads snagsby sources from Go programs without shelling out
+// to the snagsby binary.
+//
+//	env, err := snagsby.Load(ctx, "file://config/base.snagsby", "sm://production/app")
+//
+// Sources are resolved concurrently and merged in the order they are listed.
+package snagsby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/roverdotcom/snagsby/pkg/app"
+	"github.com/roverdotcom/snagsby/pkg/config"
+	"github.com/roverdotcom/snagsby/pkg/resolvers"
+)
+
+// Option configures a Loader
+type Option func(*Loader)
+
+// WithFailOnError makes Load return an error when any source fails. By
+// default failed sources are skipped, matching the snagsby command without -e.
+func WithFailOnError(failOnError bool) Option {
+	return func(l *Loader) {
+		l.failOnError = failOnError
+	}
+}
+
+// MergePolicy decides which value is kept when more than one source defines
+// the same key, it matches the snagsby command's -merge-policy flag
+type MergePolicy string
+
+const (
+	// MergeLastWins keeps the value from the last source listed
+	MergeLastWins MergePolicy = "last-wins"
+	// MergeFirstWins keeps the value from the first source listed
+	MergeFirstWins MergePolicy = "first-wins"
+	// MergeErrorOnConflict fails when sources define a key with different values
+	MergeErrorOnConflict MergePolicy = "error-on-conflict"
+)
+
+// WithMergePolicy sets how keys defined by more than one source are merged,
+// the default is MergeLastWins
+func WithMergePolicy(policy MergePolicy) Option {
+	return func(l *Loader) {
+		l.mergePolicy = policy
+	}
+}
+
+// WithTimeout bounds the resolution of each source that doesn't set its own
+// ?timeout= option, the default is config.DefaultTimeout and zero disables it
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *Loader) {
+		l.timeout = timeout
+	}
+}
+
+// WithReporter registers a function that is called with the result of every
+// source, in the order the sources were listed, before results are merged
+func WithReporter(reporter func(*SourceResult)) Option {
+	return func(l *Loader) {
+		l.reporter = reporter
+	}
+}
+
+// SourceResult holds the resolved keys, warnings and errors of a source
+type SourceResult struct {
+	Source   string
+	Items    map[string]string
+	Warnings []string
+	Errors   []error
+}
+
+// SourceError is returned when a source fails to resolve and the Loader fails
+// on errors
+type SourceError struct {
+	Source string
+	Errors []error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("snagsby source %s: %v", e.Source, errors.Join(e.Errors...))
+}
+
+// Unwrap returns the errors of the source
+func (e *SourceError) Unwrap() []error {
+	return e.Errors
+}
+
+// Loader resolves and merges snagsby sources
+type Loader struct {
+	failOnError bool
+	mergePolicy MergePolicy
+	timeout     time.Duration
+	reporter    func(*SourceResult)
+}
+
+// NewLoader returns a Loader configured with opts
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{mergePolicy: MergeLastWins, timeout: config.DefaultTimeout}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Load resolves sources and returns their merged items. When no sources are
+// given they are read from the SNAGSBY_SOURCE environment variable.
+func (l *Loader) Load(ctx context.Context, sources ...string) (map[string]string, error) {
+	policy, err := app.ParseMergePolicy(string(l.mergePolicy))
+	if err != nil {
+		return nil, err
+	}
+	snagsbyConfig := config.NewConfig()
+	if err := snagsbyConfig.SetSources(sources, os.Getenv("SNAGSBY_SOURCE")); err != nil {
+		return nil, fmt.Errorf("parsing sources: %w", err)
+	}
+	snagsbyConfig.Timeout = l.timeout
+
+	var succeeded []*resolvers.Result
+	var errs []error
+	results := app.ResolveConfigSources(ctx, snagsbyConfig)
+	app.InterpolateResults(results, policy)
+	for _, result := range results {
+		source := result.Source.URL.String()
+		if l.reporter != nil {
+			l.reporter(&SourceResult{Source: source, Items: result.Items, Warnings: result.Warnings, Errors: result.Errors})
+		}
+		if result.HasErrors() {
+			errs = append(errs, &SourceError{Source: source, Errors: result.Errors})
+			continue
+		}
+		succeeded = append(succeeded, result)
+	}
+
+	if l.failOnError && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	merged, err := app.MergeResults(succeeded, policy)
+	if err != nil {
+		return nil, err
+	}
+	return merged.Items, nil
+}
+
+// Setenv resolves sources and sets their merged items as environment
+// variables of the current process, replacing existing values
+func (l *Loader) Setenv(ctx context.Context, sources ...string) error {
+	items, err := l.Load(ctx, sources...)
+	if err != nil {
+		return err
+	}
+	for key, value := range items {
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Load resolves sources with the default Loader, see Loader.Load
+func Load(ctx context.Context, sources ...string) (map[string]string, error) {
+	return NewLoader().Load(ctx, sources...)
+}
+
+// Setenv resolves sources with the default Loader, see Loader.Setenv
+func Setenv(ctx context.Context, sources ...string) error {
+	return NewLoader().Setenv(ctx, sources...)
+}
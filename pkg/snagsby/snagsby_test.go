@@ -0,0 +1,135 @@
+package snagsby
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/roverdotcom/snagsby/pkg/app"
+)
+
+func writeEnvFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.snagsby")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+	return "file://" + path
+}
+
+func TestLoad(t *testing.T) {
+	base := writeEnvFile(t, "HOST=localhost\nPORT=5432\n")
+	override := writeEnvFile(t, "HOST=db.internal\n")
+
+	items, err := Load(context.Background(), base, override)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"HOST": "db.internal", "PORT": "5432"}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected %v, got %v", expected, items)
+	}
+}
+
+func TestLoadFromEnvironment(t *testing.T) {
+	t.Setenv("SNAGSBY_SOURCE", writeEnvFile(t, "FROM_ENV=1\n"))
+
+	items, err := Load(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if items["FROM_ENV"] != "1" {
+		t.Errorf("Expected FROM_ENV=1, got %v", items)
+	}
+}
+
+func TestLoaderOptions(t *testing.T) {
+	base := writeEnvFile(t, "HOST=localhost\n")
+	override := writeEnvFile(t, "HOST=db.internal\n")
+	missing := "file://" + filepath.Join(t.TempDir(), "missing.snagsby")
+
+	t.Run("failed sources are skipped by default", func(t *testing.T) {
+		items, err := NewLoader().Load(context.Background(), base, missing)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if items["HOST"] != "localhost" {
+			t.Errorf("Expected HOST=localhost, got %v", items)
+		}
+	})
+
+	t.Run("fail on error", func(t *testing.T) {
+		_, err := NewLoader(WithFailOnError(true)).Load(context.Background(), base, missing)
+		var sourceErr *SourceError
+		if !errors.As(err, &sourceErr) {
+			t.Fatalf("Expected a SourceError, got %v", err)
+		}
+		if sourceErr.Source != missing {
+			t.Errorf("Expected error for %s, got %s", missing, sourceErr.Source)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected the underlying not exist error, got %v", err)
+		}
+	})
+
+	t.Run("merge policy", func(t *testing.T) {
+		items, err := NewLoader(WithMergePolicy(MergeFirstWins)).Load(context.Background(), base, override)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if items["HOST"] != "localhost" {
+			t.Errorf("Expected first source to win, got %v", items)
+		}
+
+		_, err = NewLoader(WithMergePolicy(MergeErrorOnConflict)).Load(context.Background(), base, override)
+		if err == nil {
+			t.Error("Expected a conflict error")
+		}
+
+		_, err = NewLoader(WithMergePolicy("newest-wins")).Load(context.Background(), base, override)
+		if err == nil {
+			t.Error("Expected an invalid merge policy error")
+		}
+	})
+
+	t.Run("reporter sees every source in order", func(t *testing.T) {
+		var reported []string
+		reporter := func(result *SourceResult) {
+			reported = append(reported, result.Source)
+		}
+		if _, err := NewLoader(WithReporter(reporter)).Load(context.Background(), base, missing, override); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expected := []string{base, missing, override}
+		if !reflect.DeepEqual(reported, expected) {
+			t.Errorf("Expected %v, got %v", expected, reported)
+		}
+	})
+}
+
+func TestMergePolicies(t *testing.T) {
+	policies := []MergePolicy{MergeLastWins, MergeFirstWins, MergeErrorOnConflict}
+	if len(policies) != len(app.MergePolicies) {
+		t.Errorf("Expected %d merge policies, got %d", len(app.MergePolicies), len(policies))
+	}
+	for _, policy := range policies {
+		if _, err := app.ParseMergePolicy(string(policy)); err != nil {
+			t.Errorf("Expected %s to be an app merge policy, got %v", policy, err)
+		}
+	}
+}
+
+func TestSetenv(t *testing.T) {
+	t.Setenv("SNAGSBY_TEST_SETENV", "original")
+	source := writeEnvFile(t, "SNAGSBY_TEST_SETENV=replaced\n")
+
+	if err := Setenv(context.Background(), source); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value := os.Getenv("SNAGSBY_TEST_SETENV"); value != "replaced" {
+		t.Errorf("Expected SNAGSBY_TEST_SETENV=replaced, got %s", value)
+	}
+}